@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	PermManageUsers = "users:manage"
+	PermAssignRole  = "users:assign-role"
+	PermDeleteUsers = "users:delete"
+)
+
+func (app *application) adminGetUsersHandler(w http.ResponseWriter, req *http.Request) {
+
+	fq := store.UserFilterQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	users, err := app.store.Users.GetAll(req.Context(), fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) adminGetUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	target := getTargetUserFromCtx(req)
+
+	if err := app.JsonResponse(w, http.StatusOK, target); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) adminUpdateUserRoleHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	var payload types.UpdateUserRolePayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	ctx := req.Context()
+
+	role, err := app.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, req, fmt.Errorf("role %s does not exist", payload.Role))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	// nobody can hand out a role above their own, targetUserContextMiddleware
+	// already refused targets of the same level or above
+	if role.Level > user.Role.Level {
+		app.forbiddenResponse(w, req)
+		return
+	}
+
+	if err := app.store.Users.SetRole(ctx, target.ID, role.Name); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	before := *target
+	target.Role = *role
+	target.RoleId = role.ID
+
+	app.audit(req, user.ID, AuditUserRoleChange, "user", target.ID, before, target)
+
+	if err := app.JsonResponse(w, http.StatusOK, target); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) adminDeactivateUserHandler(w http.ResponseWriter, req *http.Request) {
+	app.adminSetUserActive(w, req, false)
+}
+
+func (app *application) adminReactivateUserHandler(w http.ResponseWriter, req *http.Request) {
+	app.adminSetUserActive(w, req, true)
+}
+
+func (app *application) adminSetUserActive(w http.ResponseWriter, req *http.Request, active bool) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if err := app.store.Users.SetActive(req.Context(), target.ID, active); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	before := *target
+	target.IsActive = active
+
+	action := AuditUserDeactivate
+	if active {
+		action = AuditUserReactivate
+	}
+	app.audit(req, user.ID, action, "user", target.ID, before, target)
+
+	if err := app.JsonResponse(w, http.StatusOK, target); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) adminLogoutUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if err := app.store.Users.RevokeTokens(req.Context(), target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditUserLogout, "user", target.ID, nil, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "user logged out successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) adminDeleteUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if err := app.store.Users.Delete(req.Context(), target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditUserDelete, "user", target.ID, target, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "user deleted successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// targetUserContextMiddleware loads the user from the url, inactive users included,
+// and refuses to let admins act on their own account or on users of their level or above
+func (app *application) targetUserContextMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		userId, err := strconv.ParseInt(chi.URLParam(req, "userId"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+
+		user := getUserFromCtx(req)
+
+		if userId == user.ID && req.Method != http.MethodGet {
+			app.badRequestResponse(w, req, fmt.Errorf("admins can not manage their own account"))
+			return
+		}
+
+		ctx := req.Context()
+
+		target, err := app.store.Users.GetAnyById(ctx, userId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		if req.Method != http.MethodGet && target.Role.Level >= user.Role.Level {
+			app.forbiddenResponse(w, req)
+			return
+		}
+
+		ctx = context.WithValue(ctx, targetUserCtx, target)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
@@ -7,7 +7,11 @@ import (
 	"social/internal/auth"
 	"social/internal/env"
 	"social/internal/mailer"
+	"social/internal/notifications"
+	"social/internal/pubsub"
 	"social/internal/store"
+	"social/internal/timeline"
+	"social/internal/webhooks"
 	"time"
 
 	chi "github.com/go-chi/chi/v5"
@@ -20,6 +24,11 @@ type application struct {
 	db            *sql.DB
 	mailer        mailer.Client
 	authenticator auth.Authenticator
+	permissions   *permissionCache
+	notifications *notifications.Service
+	events        pubsub.PubSub
+	webhooks      *webhooks.Dispatcher
+	timeline      *timeline.Service
 }
 
 func (app *application) mount() http.Handler {
@@ -31,39 +40,199 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	// streams stay open, the request timeout only applies to the rest of /v1
+	r.With(app.AuthTokenMiddleware, app.requireScope("feed:read")).Get("/v1/stream", app.streamHandler)
 
 	// v1
-	r.Route("/v1", func(r chi.Router) {
+	r.With(middleware.Timeout(60*time.Second)).Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
 
 		// POSTS
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
+			r.With(app.requireScope("posts:write")).Post("/", app.createPostHandler)
+			r.With(app.requireScope("posts:read")).Get("/drafts", app.getDraftsHandler)
+			r.With(app.requireScope("posts:read")).Get("/scheduled", app.getScheduledPostsHandler)
 			r.Route("/{postId}", func(r chi.Router) {
 
-				r.Use(app.postsContextMiddleware)
+				// deleted posts are not loaded by postsContextMiddleware
+				r.With(app.requireScope("posts:write")).Post("/restore", app.restorePostHandler)
 
-				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				r.Group(func(r chi.Router) {
+					r.Use(app.postsContextMiddleware)
+
+					r.With(app.requireScope("posts:read")).Get("/", app.getPostHandler)
+					r.With(app.requireScope("posts:write"), app.requirePostIfMatch).Patch("/", app.checkPostOwnership(PermUpdateAnyPost, app.updatePostHandler))
+					r.With(app.requireScope("posts:write"), app.requirePostIfMatch).Delete("/", app.checkPostOwnership(PermDeleteAnyPost, app.deletePostHandler))
+					r.With(app.requireScope("posts:write")).Put("/schedule", app.schedulePostHandler)
+					r.With(app.requireScope("posts:write")).Delete("/schedule", app.cancelPostScheduleHandler)
+					r.With(app.requireScope("posts:write")).Put("/publish", app.publishPostHandler)
+					r.With(app.requireScope("posts:read"), app.revisionsAccessMiddleware).Get("/revisions", app.getPostRevisionsHandler)
+					r.With(app.requireScope("posts:read"), app.revisionsAccessMiddleware).Get("/revisions/diff", app.getPostDiffHandler)
+					r.With(app.requireScope("posts:write")).Put("/repost", app.repostHandler)
+					r.With(app.requireScope("posts:write")).Delete("/repost", app.unrepostHandler)
+					r.With(app.requireScope("posts:write")).Put("/pin", app.pinPostHandler)
+					r.With(app.requireScope("posts:write")).Delete("/pin", app.unpinPostHandler)
+					r.With(app.requireScope("posts:write")).Put("/bookmark", app.bookmarkPostHandler)
+					r.With(app.requireScope("posts:write")).Delete("/bookmark", app.unbookmarkPostHandler)
+					r.With(app.requireScope("posts:write")).Post("/comments", app.createCommentHandler)
+				})
 			})
 		})
 
+		// EXPLORE
+		r.Route("/explore", func(r chi.Router) {
+			r.Use(app.optionalAuthMiddleware)
+			r.Use(app.requireScope("posts:read"))
+			r.Get("/", app.getGlobalTimelineHandler)
+			r.Get("/popular", app.getPopularTimelineHandler)
+		})
+
+		// TAGS
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.With(app.requireScope("posts:read")).Get("/trending", app.getTrendingTagsHandler)
+			r.With(app.requireScope("posts:read")).Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
+		// NOTIFICATIONS
+		r.Route("/notifications", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope("notifications:read"))
+			r.Get("/", app.getNotificationsHandler)
+			r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+			r.Put("/read", app.markAllNotificationsReadHandler)
+			r.Put("/{notificationId}/read", app.markNotificationReadHandler)
+			r.Get("/preferences", app.getNotificationPreferencesHandler)
+			r.Put("/preferences", app.updateNotificationPreferencesHandler)
+		})
+
+		// CONVERSATIONS
+		r.Route("/conversations", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.With(app.requireScope("messages:read")).Get("/", app.getConversationsHandler)
+			r.With(app.requireScope("messages:write")).Post("/", app.createConversationHandler)
+			r.Route("/{conversationId}", func(r chi.Router) {
+				r.Use(app.conversationContextMiddleware)
+				r.With(app.requireScope("messages:read")).Get("/", app.getConversationHandler)
+				r.With(app.requireScope("messages:read")).Get("/messages", app.getMessagesHandler)
+				r.With(app.requireScope("messages:write")).Post("/messages", app.sendMessageHandler)
+				r.With(app.requireScope("messages:read")).Put("/read", app.markConversationReadHandler)
+				r.With(app.requireScope("messages:read")).Put("/messages/{messageId}/read", app.markConversationReadHandler)
+			})
+		})
+
+		// WEBHOOKS
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.sessionOnlyMiddleware)
+			r.Get("/", app.getWebhooksHandler)
+			r.Post("/", app.createWebhookHandler)
+			r.Route("/{webhookId}", func(r chi.Router) {
+				r.Use(app.webhookContextMiddleware)
+				r.Get("/", app.getWebhookHandler)
+				r.Patch("/", app.updateWebhookHandler)
+				r.Delete("/", app.deleteWebhookHandler)
+				r.Get("/deliveries", app.getWebhookDeliveriesHandler)
+				r.Post("/deliveries/{deliveryId}/replay", app.replayWebhookDeliveryHandler)
+			})
+		})
+
+		// COMMENTS
+		r.Route("/comments", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.With(app.requireScope("posts:write")).Post("/{commentId}/restore", app.restoreCommentHandler)
+			r.With(app.requireScope("posts:write"), app.commentContextMiddleware).Patch("/{commentId}", app.updateCommentHandler)
+		})
+
 		// USERS
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				// api keys can not be used to manage other api keys
+				r.Route("/api-keys", func(r chi.Router) {
+					r.Use(app.sessionOnlyMiddleware)
+					r.Post("/", app.createApiKeyHandler)
+					r.Get("/", app.getApiKeysHandler)
+					r.Delete("/{keyId}", app.revokeApiKeyHandler)
+				})
+
+				r.With(app.requireScope("users:write")).Patch("/", app.updateMeHandler)
+				r.With(app.requireScope("users:read")).Get("/follow-requests", app.getFollowRequestsHandler)
+				r.With(app.requireScope("users:write")).Put("/follow-requests/{requesterId}/approve", app.approveFollowRequestHandler)
+				r.With(app.requireScope("users:write")).Put("/follow-requests/{requesterId}/reject", app.rejectFollowRequestHandler)
+				r.With(app.requireScope("users:read")).Get("/blocks", app.getBlockedUsersHandler)
+				r.With(app.requireScope("users:read")).Get("/mutes", app.getMutedUsersHandler)
+				r.With(app.requireScope("posts:read")).Get("/bookmarks", app.getBookmarksHandler)
+				r.With(app.requireScope("posts:read")).Get("/mentions", app.getMentionsHandler)
+			})
 			r.Route("/{userId}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				r.Use(app.userContextMiddleware)
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope("users:read")).Get("/", app.getUserHandler)
+				r.With(app.requireScope("posts:read")).Get("/posts", app.getUserPostsHandler)
+				r.With(app.requireScope("users:write")).Put("/follow", app.followUserHandler)
+				r.With(app.requireScope("users:write")).Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope("users:write")).Put("/block", app.blockUserHandler)
+				r.With(app.requireScope("users:write")).Put("/unblock", app.unblockUserHandler)
+				r.With(app.requireScope("users:write")).Put("/mute", app.muteUserHandler)
+				r.With(app.requireScope("users:write")).Put("/unmute", app.unmuteUserHandler)
 			})
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.requireScope("feed:read")).Get("/feed", app.getUserFeedHandler)
+			})
+		})
+
+		// REPORTS
+		r.Route("/reports", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.With(app.requireScope("posts:write")).Post("/", app.createReportHandler)
+		})
+
+		// MODERATION
+		r.Route("/moderation", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.sessionOnlyMiddleware)
+			r.Use(app.requirePermission(PermModerateReports))
+
+			r.Get("/reports", app.getReportsHandler)
+			r.Route("/reports/{reportId}", func(r chi.Router) {
+				r.Use(app.reportContextMiddleware)
+				r.Get("/", app.getReportHandler)
+				r.Post("/resolve", app.resolveReportHandler)
+			})
+		})
+
+		// ADMIN
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.sessionOnlyMiddleware)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requirePermission(PermManageRoles))
+				r.Get("/roles", app.getRolesHandler)
+				r.Get("/permissions", app.getPermissionsHandler)
+				r.Put("/roles/{roleId}/permissions/{permission}", app.grantPermissionHandler)
+				r.Delete("/roles/{roleId}/permissions/{permission}", app.revokePermissionHandler)
+			})
+
+			r.With(app.requirePermission(PermReadAuditLog)).Get("/audit-logs", app.getAuditLogsHandler)
+
+			r.Route("/users", func(r chi.Router) {
+				r.Use(app.requirePermission(PermManageUsers))
+				r.Get("/", app.adminGetUsersHandler)
+				r.Route("/{userId}", func(r chi.Router) {
+					r.Use(app.targetUserContextMiddleware)
+					r.Get("/", app.adminGetUserHandler)
+					r.With(app.requirePermission(PermAssignRole)).Put("/role", app.adminUpdateUserRoleHandler)
+					r.Put("/deactivate", app.adminDeactivateUserHandler)
+					r.Put("/reactivate", app.adminReactivateUserHandler)
+					r.Post("/logout", app.adminLogoutUserHandler)
+					r.With(app.requirePermission(PermDeleteUsers)).Delete("/", app.adminDeleteUserHandler)
+				})
 			})
 		})
 
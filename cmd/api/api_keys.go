@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type apiKeyKey string
+
+const apiKeyCtx apiKeyKey = "apiKey"
+
+func (app *application) createApiKeyHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	var payload types.CreateApiKeyPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	apiKey := &types.ApiKey{
+		UserId:  user.ID,
+		Name:    payload.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  payload.Scopes,
+	}
+
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = []string{}
+	}
+
+	if payload.ExpiresIn != nil {
+		expiresAt := time.Now().Add(time.Hour * 24 * time.Duration(*payload.ExpiresIn)).Format(time.RFC3339)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := app.store.ApiKeys.Create(req.Context(), apiKey); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	app.audit(req, user.ID, AuditApiKeyCreate, "api_key", apiKey.ID, nil, apiKey)
+
+	// the plain key is only returned once, at creation time
+	keyWithSecret := types.ApiKeyWithSecret{
+		ApiKey: apiKey,
+		Key:    key,
+	}
+
+	if err := app.JsonResponse(w, http.StatusCreated, keyWithSecret); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getApiKeysHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	keys, err := app.store.ApiKeys.GetByUserId(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, keys); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) revokeApiKeyHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	keyId, err := strconv.ParseInt(chi.URLParam(req, "keyId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := app.store.ApiKeys.Revoke(req.Context(), keyId, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditApiKeyRevoke, "api_key", keyId, nil, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "api key revoked successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func getApiKeyFromCtx(req *http.Request) *types.ApiKey {
+	key, ok := req.Context().Value(apiKeyCtx).(*types.ApiKey)
+	if !ok {
+		return nil
+	}
+	return key
+}
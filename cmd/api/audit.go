@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"social/internal/store"
+	"social/internal/types"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const PermReadAuditLog = "audit:read"
+
+// audited actions
+const (
+	AuditLogin            = "auth.login"
+	AuditLoginFailed      = "auth.login.failed"
+	AuditApiKeyCreate     = "api_key.create"
+	AuditApiKeyRevoke     = "api_key.revoke"
+	AuditPostModerate     = "post.update.moderated"
+	AuditPostDelete       = "post.delete"
+	AuditPostRestore      = "post.restore"
+	AuditCommentRestore   = "comment.restore"
+	AuditUserRoleChange   = "user.role.change"
+	AuditUserDeactivate   = "user.deactivate"
+	AuditUserReactivate   = "user.reactivate"
+	AuditUserLogout       = "user.logout.forced"
+	AuditUserDelete       = "user.delete"
+	AuditPermissionGrant  = "role.permission.grant"
+	AuditPermissionRevoke = "role.permission.revoke"
+	AuditWebhookCreate    = "webhook.create"
+	AuditWebhookUpdate    = "webhook.update"
+	AuditWebhookDelete    = "webhook.delete"
+)
+
+// audit appends an entry to the audit log, before and after are reduced to the fields that changed.
+// failures are logged and never fail the request that is being audited
+func (app *application) audit(req *http.Request, actorId int64, action, targetType string, targetId int64, before, after any) {
+
+	entry := &types.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		RequestId:  middleware.GetReqID(req.Context()),
+		IP:         req.RemoteAddr,
+	}
+
+	if actorId != 0 {
+		entry.ActorId = &actorId
+	}
+
+	if targetId != 0 {
+		entry.TargetId = &targetId
+	}
+
+	var err error
+	entry.Before, entry.After, err = auditDiff(before, after)
+	if err != nil {
+		log.Printf("error building audit diff for %s: %s", action, err.Error())
+	}
+
+	// the request context may be canceled as soon as the response is written
+	ctx := context.WithoutCancel(req.Context())
+
+	if err := app.store.AuditLogs.Create(ctx, entry); err != nil {
+		log.Printf("error writing audit log for %s: %s", action, err.Error())
+	}
+}
+
+// auditDiff marshals both snapshots and drops the top level fields that are equal in both
+func auditDiff(before, after any) (json.RawMessage, json.RawMessage, error) {
+
+	b, err := toAuditMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a, err := toAuditMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		for key, value := range b {
+			if reflect.DeepEqual(value, a[key]) {
+				delete(b, key)
+				delete(a, key)
+			}
+		}
+	}
+
+	beforeJSON, err := marshalAuditMap(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	afterJSON, err := marshalAuditMap(a)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return beforeJSON, afterJSON, nil
+}
+
+func toAuditMap(v any) (map[string]any, error) {
+
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]any{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func marshalAuditMap(m map[string]any) (json.RawMessage, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+func (app *application) getAuditLogsHandler(w http.ResponseWriter, req *http.Request) {
+
+	fq := store.AuditLogQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	entries, err := app.store.AuditLogs.GetAll(req.Context(), fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, entries); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// runAuditLogRetention removes the entries older than the configured retention once per interval
+func (app *application) runAuditLogRetention(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := app.store.AuditLogs.DeleteOlderThan(ctx, time.Now().Add(-app.config.AuditLogRetention))
+		if err != nil {
+			log.Println("error applying audit log retention: ", err.Error())
+		} else if deleted > 0 {
+			log.Printf("audit log retention removed %d entries", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
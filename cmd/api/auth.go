@@ -124,6 +124,7 @@ func (app *application) createTokenHandler(w http.ResponseWriter, req *http.Requ
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
+			app.audit(req, 0, AuditLoginFailed, "", 0, nil, map[string]string{"email": payload.Email})
 			app.unAuthorizedError(w, req, err)
 			return
 		default:
@@ -134,6 +135,7 @@ func (app *application) createTokenHandler(w http.ResponseWriter, req *http.Requ
 
 	// check password is valid
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password)); err != nil {
+		app.audit(req, 0, AuditLoginFailed, "user", user.ID, nil, map[string]string{"email": payload.Email})
 		app.badRequestResponse(w, req, fmt.Errorf("invalid password"))
 		return
 	}
@@ -154,6 +156,8 @@ func (app *application) createTokenHandler(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	app.audit(req, user.ID, AuditLogin, "user", user.ID, nil, nil)
+
 	// return json response
 	if err := app.JsonResponse(w, http.StatusCreated, token); err != nil {
 		app.internalServerError(w, req, err)
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/store"
+)
+
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if err := app.store.Bookmarks.Create(req.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, fmt.Errorf("post already bookmarked"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusCreated, map[string]string{"message": "post bookmarked"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if err := app.store.Bookmarks.Delete(req.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "bookmark removed"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getBookmarksHandler(w http.ResponseWriter, req *http.Request) {
+
+	// same pagination, sort and search as the feed
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	bookmarks, err := app.store.Bookmarks.GetByUserId(req.Context(), viewer, fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, bookmarks); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
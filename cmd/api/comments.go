@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"social/internal/pubsub"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+func (app *application) createCommentHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if post.Status != types.PostPublished {
+		app.conflictError(w, req, fmt.Errorf("post is not published"))
+		return
+	}
+
+	var payload types.CreateCommentPayload
+
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	comment := &types.Comment{
+		PostId:  post.ID,
+		UserId:  user.ID,
+		Content: payload.Content,
+		User:    types.User{ID: user.ID, Username: user.Username},
+	}
+
+	ctx := req.Context()
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	app.publishEvent(ctx, pubsub.PostTopic(post.ID), EventCommentCreated, streamRef{PostId: post.ID, CommentId: comment.ID, UserId: user.ID})
+	app.notifications.Publish(ctx, post.UserId, user.ID, types.NotificationComment, "post", post.ID)
+	app.webhooks.Publish(ctx, types.WebhookCommentCreated, []int64{post.UserId, user.ID}, comment)
+	app.notifyCommentMentions(ctx, post, comment, nil)
+
+	if err := app.JsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) updateCommentHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	comment := getCommentFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if comment.UserId != user.ID {
+		app.forbiddenResponse(w, req)
+		return
+	}
+
+	var payload types.UpdateCommentPayload
+
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	comment.Content = payload.Content
+	previous := comment.MentionedUserIds
+
+	if err := app.store.Comments.Update(req.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.notifyCommentMentions(req.Context(), post, comment, previous)
+
+	if err := app.JsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// commentContextMiddleware loads the comment from the url and the post it belongs to,
+// the viewer has to be able to see both
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		commentId, err := strconv.ParseInt(chi.URLParam(req, "commentId"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+
+		ctx := req.Context()
+
+		comment, err := app.store.Comments.GetById(ctx, commentId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		post, err := app.store.Posts.GetPostById(ctx, comment.PostId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		viewer, err := app.getViewer(req)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		allowed, err := app.canViewPost(ctx, viewer, post)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		if allowed && comment.UserId != viewer.ID {
+			rel, err := app.store.Users.GetRelationship(ctx, viewer.ID, comment.UserId)
+			if err != nil {
+				app.internalServerError(w, req, err)
+				return
+			}
+			allowed = viewer.CanViewComment(comment, rel)
+		}
+
+		if !allowed {
+			app.notFoundError(w, req, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		ctx = context.WithValue(ctx, postCtx, post)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(req *http.Request) *types.Comment {
+	comment, ok := req.Context().Value(commentCtx).(*types.Comment)
+	if !ok {
+		log.Println("error: failed to retrieve comment from context")
+		return nil
+	}
+	return comment
+}
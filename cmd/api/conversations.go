@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"social/internal/pubsub"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type conversationKey string
+
+const conversationCtx conversationKey = "conversation"
+
+func (app *application) getConversationsHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	cq := store.ConversationQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	cq, err := cq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	conversations, err := app.store.Conversations.GetByUserId(req.Context(), user.ID, cq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, conversations); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) createConversationHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	ctx := req.Context()
+
+	var payload types.CreateConversationPayload
+
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	participantIds := []int64{}
+	for _, id := range payload.ParticipantIds {
+		if id != user.ID && !slices.Contains(participantIds, id) {
+			participantIds = append(participantIds, id)
+		}
+	}
+
+	if len(participantIds) == 0 {
+		app.badRequestResponse(w, req, fmt.Errorf("you can not start a conversation with yourself"))
+		return
+	}
+
+	for _, id := range participantIds {
+		if _, err := app.store.Users.GetById(ctx, id); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		rel, err := app.store.Users.GetRelationship(ctx, user.ID, id)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		// blocked users are invisible to each other
+		if rel.Blocked() {
+			app.notFoundError(w, req, store.ErrNotFound)
+			return
+		}
+	}
+
+	if len(participantIds) == 1 && payload.Title == "" {
+		conversation, created, err := app.store.Conversations.GetOrCreateDirect(ctx, user.ID, participantIds[0])
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+
+		if err := app.JsonResponse(w, status, conversation); err != nil {
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	conversation := &types.Conversation{
+		Title:     payload.Title,
+		CreatedBy: &user.ID,
+	}
+
+	if err := app.store.Conversations.CreateGroup(ctx, conversation, append(participantIds, user.ID)); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusCreated, conversation); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getConversationHandler(w http.ResponseWriter, req *http.Request) {
+
+	conversation := getConversationFromCtx(req)
+
+	if err := app.JsonResponse(w, http.StatusOK, conversation); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getMessagesHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	conversation := getConversationFromCtx(req)
+
+	mq := store.MessageQuery{
+		Limit: 50,
+	}
+
+	mq, err := mq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(mq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	messages, err := app.store.Conversations.GetMessages(req.Context(), conversation.ID, user.ID, mq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, messages); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) sendMessageHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	conversation := getConversationFromCtx(req)
+	ctx := req.Context()
+
+	var payload types.CreateMessagePayload
+
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	// the participants who can receive the message, blocks split a group for the two users involved
+	recipients := []int64{}
+	for _, participant := range conversation.Participants {
+		if participant.UserId == user.ID {
+			continue
+		}
+
+		rel, err := app.store.Users.GetRelationship(ctx, user.ID, participant.UserId)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		if rel.Blocked() {
+			if !conversation.IsGroup {
+				app.forbiddenResponse(w, req)
+				return
+			}
+			continue
+		}
+
+		recipients = append(recipients, participant.UserId)
+	}
+
+	message := &types.Message{
+		ConversationId: conversation.ID,
+		SenderId:       user.ID,
+		Sender:         types.User{ID: user.ID, Username: user.Username},
+		Content:        payload.Content,
+	}
+
+	if err := app.store.Conversations.CreateMessage(ctx, message); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	// the other sessions of the sender get it as well
+	for _, userId := range append(recipients, user.ID) {
+		app.publishEvent(ctx, pubsub.UserTopic(userId), EventMessageCreated, message)
+	}
+
+	if err := app.JsonResponse(w, http.StatusCreated, message); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// markConversationReadHandler moves the read receipt of the user to the message from the url,
+// or to the latest message of the conversation
+func (app *application) markConversationReadHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	conversation := getConversationFromCtx(req)
+	ctx := req.Context()
+
+	var messageId int64
+	if param := chi.URLParam(req, "messageId"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+		messageId = id
+	}
+
+	lastRead, err := app.store.Conversations.MarkRead(ctx, conversation.ID, user.ID, messageId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	receipt := map[string]int64{
+		"conversation_id": conversation.ID,
+		"user_id":         user.ID,
+		"message_id":      lastRead,
+	}
+
+	for _, participant := range conversation.Participants {
+		app.publishEvent(ctx, pubsub.UserTopic(participant.UserId), EventMessageRead, receipt)
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, receipt); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// conversationContextMiddleware loads the conversation from the url, users who do not take part in it get a 404
+func (app *application) conversationContextMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		conversationId, err := strconv.ParseInt(chi.URLParam(req, "conversationId"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+
+		user := getUserFromCtx(req)
+		ctx := req.Context()
+
+		conversation, err := app.store.Conversations.GetById(ctx, conversationId, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, conversationCtx, conversation)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(req *http.Request) *types.Conversation {
+	conversation, ok := req.Context().Value(conversationCtx).(*types.Conversation)
+	if !ok {
+		log.Println("error: failed to retrieve conversation from context")
+		return nil
+	}
+	return conversation
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// canRestore lets authors restore what they deleted themselves,
+// anything deleted by someone else needs the given permission
+func (app *application) canRestore(req *http.Request, authorId int64, deletedBy *int64, permission string) (bool, error) {
+
+	user := getUserFromCtx(req)
+
+	if authorId == user.ID && deletedBy != nil && *deletedBy == user.ID {
+		return true, nil
+	}
+
+	return app.hasPermission(req.Context(), user, permission)
+}
+
+func (app *application) restorePostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	ctx := req.Context()
+
+	postId, err := strconv.ParseInt(chi.URLParam(req, "postId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	post, err := app.store.Posts.GetDeletedById(ctx, postId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	allowed, err := app.canRestore(req, post.UserId, post.DeletedBy, PermDeleteAnyPost)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	// deleted posts stay invisible to everyone else
+	if !allowed {
+		app.notFoundError(w, req, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Posts.Restore(ctx, post.ID, time.Now().Add(-app.config.DeleteGracePeriod)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictError(w, req, fmt.Errorf("the grace period to restore this post is over"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditPostRestore, "post", post.ID, nil, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "post restored successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) restoreCommentHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	ctx := req.Context()
+
+	commentId, err := strconv.ParseInt(chi.URLParam(req, "commentId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	comment, err := app.store.Comments.GetDeletedById(ctx, commentId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	allowed, err := app.canRestore(req, comment.UserId, comment.DeletedBy, PermModerateComments)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundError(w, req, store.ErrNotFound)
+		return
+	}
+
+	// the post has to come back first, a comment can not live on a deleted post
+	if _, err := app.store.Posts.GetDeletedById(ctx, comment.PostId); err == nil {
+		app.conflictError(w, req, fmt.Errorf("the post of this comment is deleted"))
+		return
+	} else if !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.store.Comments.Restore(ctx, comment.ID, time.Now().Add(-app.config.DeleteGracePeriod)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictError(w, req, fmt.Errorf("the grace period to restore this comment is over"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditCommentRestore, "comment", comment.ID, nil, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "comment restored successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// runDeletedPurge hard deletes the posts and comments deleted longer than the retention ago
+func (app *application) runDeletedPurge(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().Add(-app.config.DeleteRetention)
+
+		posts, err := app.store.Posts.PurgeDeleted(ctx, before)
+		if err != nil {
+			log.Println("error purging deleted posts: ", err.Error())
+		} else if posts > 0 {
+			log.Printf("purged %d deleted posts", posts)
+		}
+
+		comments, err := app.store.Comments.PurgeDeleted(ctx, before)
+		if err != nil {
+			log.Println("error purging deleted comments: ", err.Error())
+		} else if comments > 0 {
+			log.Printf("purged %d deleted comments", comments)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"social/internal/store"
+	"social/internal/types"
+	"time"
+)
+
+// parsePublishAt reads the publication time of a scheduled post, it has to be in the future
+func parsePublishAt(value string) (time.Time, error) {
+
+	publishAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !publishAt.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("publish_at must be in the future")
+	}
+
+	return publishAt, nil
+}
+
+func (app *application) getDraftsHandler(w http.ResponseWriter, req *http.Request) {
+	app.getPostsByStatus(w, req, types.PostDraft)
+}
+
+func (app *application) getScheduledPostsHandler(w http.ResponseWriter, req *http.Request) {
+	app.getPostsByStatus(w, req, types.PostScheduled)
+}
+
+func (app *application) getPostsByStatus(w http.ResponseWriter, req *http.Request, status string) {
+
+	user := getUserFromCtx(req)
+
+	posts, err := app.store.Posts.GetByStatus(req.Context(), user.ID, status)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) schedulePostHandler(w http.ResponseWriter, req *http.Request) {
+
+	post := getPostFromCtx(req)
+
+	var payload types.SchedulePostPayload
+
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	publishAt, err := parsePublishAt(payload.PublishAt)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	app.setPostSchedule(w, req, post, &publishAt)
+}
+
+func (app *application) cancelPostScheduleHandler(w http.ResponseWriter, req *http.Request) {
+
+	post := getPostFromCtx(req)
+
+	if post.Status != types.PostScheduled {
+		app.conflictError(w, req, fmt.Errorf("post is not scheduled"))
+		return
+	}
+
+	app.setPostSchedule(w, req, post, nil)
+}
+
+func (app *application) setPostSchedule(w http.ResponseWriter, req *http.Request, post *types.Post, publishAt *time.Time) {
+
+	user := getUserFromCtx(req)
+
+	if post.UserId != user.ID {
+		app.forbiddenResponse(w, req)
+		return
+	}
+
+	if post.Status == types.PostPublished {
+		app.conflictError(w, req, fmt.Errorf("post is already published"))
+		return
+	}
+
+	if err := app.store.Posts.Schedule(req.Context(), post.ID, publishAt); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictError(w, req, fmt.Errorf("post is already published"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	post.Status = types.PostDraft
+	post.PublishAt = nil
+	if publishAt != nil {
+		value := publishAt.Format(time.RFC3339)
+		post.Status = types.PostScheduled
+		post.PublishAt = &value
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) publishPostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if post.UserId != user.ID {
+		app.forbiddenResponse(w, req)
+		return
+	}
+
+	if err := app.store.Posts.Publish(req.Context(), post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictError(w, req, fmt.Errorf("post is already published"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	post.Status = types.PostPublished
+	post.PublishAt = nil
+
+	app.attachQuotedPost(req.Context(), post)
+
+	app.notifyPostPublished(req.Context(), post, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// runPostScheduler publishes scheduled posts once their publication time has passed
+func (app *application) runPostScheduler(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := app.store.Posts.PublishDue(ctx)
+		if err != nil {
+			log.Println("error publishing scheduled posts: ", err.Error())
+		} else if len(ids) > 0 {
+			log.Printf("post scheduler published %d posts", len(ids))
+		}
+
+		for _, id := range ids {
+			app.notifyScheduledPost(ctx, id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notifyScheduledPost sends the notifications of a post published by the scheduler
+func (app *application) notifyScheduledPost(ctx context.Context, postId int64) {
+
+	post, err := app.store.Posts.GetPostById(ctx, postId)
+	if err != nil {
+		log.Printf("error loading scheduled post %d: %s", postId, err.Error())
+		return
+	}
+
+	app.attachQuotedPost(ctx, post)
+
+	app.notifyPostPublished(ctx, post, nil)
+}
+
+// attachQuotedPost sets the quoted post of a post being published when its author can still see it
+func (app *application) attachQuotedPost(ctx context.Context, post *types.Post) {
+
+	if post.QuotePostId == nil {
+		return
+	}
+
+	quoted, err := app.getQuotedPost(ctx, store.Viewer{ID: post.UserId}, *post.QuotePostId)
+	if err != nil {
+		log.Printf("error loading the post quoted by post %d: %s", post.ID, err.Error())
+		return
+	}
+
+	post.QuotedPost = quoted
+}
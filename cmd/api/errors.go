@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"social/internal/types"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, req *http.Request, err error) {
@@ -42,6 +45,21 @@ func (app *application) conflictError(w http.ResponseWriter, req *http.Request,
 	WriteError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) preconditionFailedError(w http.ResponseWriter, req *http.Request, err error) {
+	log.Printf("precondition failed error: %s path: %s error: %s", req.Method, req.URL.Path, err)
+	WriteError(w, http.StatusPreconditionFailed, err.Error())
+}
+
+func (app *application) preconditionRequiredError(w http.ResponseWriter, req *http.Request, err error) {
+	log.Printf("precondition required error: %s path: %s error: %s", req.Method, req.URL.Path, err)
+	WriteError(w, http.StatusPreconditionRequired, err.Error())
+}
+
+func (app *application) suspendedResponse(w http.ResponseWriter, req *http.Request, user *types.User) {
+	log.Printf("suspended error: %s path: %s user: %d", req.Method, req.URL.Path, user.ID)
+	WriteError(w, http.StatusForbidden, fmt.Sprintf("account suspended until %s", user.SuspendedUntil.Format(time.RFC3339)))
+}
+
 func (app *application) unAuthorizedError(w http.ResponseWriter, req *http.Request, err error) {
 	log.Printf("unauthorized error: %s path: %s error: %s", req.Method, req.URL.Path, err)
 	WriteError(w, http.StatusUnauthorized, "unauthorized")
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"social/internal/types"
+	"strconv"
+	"strings"
+)
+
+// postETag identifies the post as it is returned, the version it was read at followed by a hash
+// of the response so comments, bookmarks, moderation and the quoted post change it as well
+func postETag(post *types.Post) (string, error) {
+
+	body, err := json.Marshal(post)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+
+	return fmt.Sprintf(`"%d-%x"`, post.Version, sum[:8]), nil
+}
+
+// matchETag reports whether the etag is in the list of a If-None-Match header
+func matchETag(header, etag string) bool {
+
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchVersion reports whether a If-Match header holds an etag of the given version,
+// writes only depend on the version so the hash part of the etag is not compared
+func matchVersion(header string, version int) bool {
+
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" {
+			return true
+		}
+
+		value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+		value, _, _ = strings.Cut(value, "-")
+
+		if parsed, err := strconv.Atoi(value); err == nil && parsed == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requirePostIfMatch rejects writes that are not based on the current version of the post,
+// the etag read by the client has to be sent back in If-Match
+func (app *application) requirePostIfMatch(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		post := getPostFromCtx(req)
+
+		header := req.Header.Get("If-Match")
+		if header == "" {
+			app.preconditionRequiredError(w, req, fmt.Errorf("If-Match header is required"))
+			return
+		}
+
+		if !matchVersion(header, post.Version) {
+			app.preconditionFailedError(w, req, fmt.Errorf("post was modified, current version is %d", post.Version))
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"social/internal/content"
+	"social/internal/store"
+	"social/internal/types"
+)
+
+// how long clients and shared caches may keep an explore page
+const exploreMaxAge = "60"
+
+func (app *application) getGlobalTimelineHandler(w http.ResponseWriter, req *http.Request) {
+
+	fq, viewer, ok := app.parseExploreQuery(w, req)
+	if !ok {
+		return
+	}
+
+	posts, err := app.store.Explore.GetGlobal(req.Context(), viewer, fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	app.writeExplore(w, req, viewer, posts)
+}
+
+func (app *application) getPopularTimelineHandler(w http.ResponseWriter, req *http.Request) {
+
+	fq, viewer, ok := app.parseExploreQuery(w, req)
+	if !ok {
+		return
+	}
+
+	posts, err := app.store.Explore.GetPopular(req.Context(), viewer, fq, app.config.TrendingWindow, app.config.TrendingHalfLife)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	app.writeExplore(w, req, viewer, posts)
+}
+
+// parseExploreQuery reads the feed query of an explore request and its viewer,
+// anonymous requests get a zero viewer. the response is written when ok is false
+func (app *application) parseExploreQuery(w http.ResponseWriter, req *http.Request) (store.PaginatedFeedQuery, store.Viewer, bool) {
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return fq, store.Viewer{}, false
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return fq, store.Viewer{}, false
+	}
+
+	for i, tag := range fq.Tags {
+		fq.Tags[i], err = content.NormalizeTag(tag)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return fq, store.Viewer{}, false
+		}
+	}
+
+	if _, ok := req.Context().Value(userCtx).(*types.User); !ok {
+		return fq, store.Viewer{}, true
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return fq, store.Viewer{}, false
+	}
+
+	return fq, viewer, true
+}
+
+// writeExplore sends an explore page, anonymous pages are the same for everyone and can be kept by shared caches
+func (app *application) writeExplore(w http.ResponseWriter, req *http.Request, viewer store.Viewer, posts []types.PostWithMetadata) {
+
+	w.Header().Set("Vary", "Authorization")
+	if viewer.ID == 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+exploreMaxAge)
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age="+exploreMaxAge)
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 	"social/internal/store"
+	"social/internal/types"
+	"strconv"
 )
 
+const (
+	FeedLatest = "latest"
+	FeedRanked = "ranked"
+)
+
+// getUserFeedHandler returns the home timeline, ?mode=ranked sorts it by score instead of time
+// and ?debug=true adds the score breakdown of every post of the ranked feed
 func (app *application) getUserFeedHandler(w http.ResponseWriter, req *http.Request) {
 
 	// pagination, filters, sort
@@ -25,17 +36,145 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	qs := req.URL.Query()
+
+	mode := qs.Get("mode")
+	if mode == "" {
+		mode = FeedLatest
+	}
+	if mode != FeedLatest && mode != FeedRanked {
+		app.badRequestResponse(w, req, fmt.Errorf("unknown feed mode %s", mode))
+		return
+	}
+
+	// the ranked feed is always sorted by score
+	if mode == FeedRanked && qs.Has("sort") {
+		app.badRequestResponse(w, req, fmt.Errorf("sort is not supported by the ranked feed"))
+		return
+	}
+
+	debug, err := parseBoolParam(qs, "debug")
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
 	ctx := req.Context()
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(1), fq)
+	var feed []types.PostWithMetadata
+
+	switch mode {
+	case FeedRanked:
+		feed, err = app.store.Timelines.GetRanked(ctx, viewer, fq, app.ranking())
+	default:
+		feed, err = app.store.Timelines.Get(ctx, viewer, fq)
+	}
 	if err != nil {
 		app.internalServerError(w, req, err)
 		return
 	}
 
+	if !debug {
+		for i := range feed {
+			feed[i].Score = nil
+		}
+	}
+
 	if err := app.JsonResponse(w, http.StatusOK, feed); err != nil {
 		app.internalServerError(w, req, err)
 		return
 	}
 
 }
+
+// getUserPostsHandler returns the profile timeline of a user, pinned posts first.
+// ?replies=true adds the comments of the user with their posts and ?reposts=true the reposts of the user
+func (app *application) getUserPostsHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getTargetUserFromCtx(req)
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	qs := req.URL.Query()
+
+	var include store.ProfileInclude
+
+	if include.Replies, err = parseBoolParam(qs, "replies"); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if include.Reposts, err = parseBoolParam(qs, "reposts"); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	posts, err := app.store.Timelines.GetProfile(req.Context(), viewer, user.ID, fq, include)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// parseBoolParam reads an optional boolean query parameter, false when it is missing
+func parseBoolParam(qs url.Values, name string) (bool, error) {
+
+	value := qs.Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter: %w", name, err)
+	}
+
+	return parsed, nil
+}
+
+func (app *application) ranking() store.Ranking {
+
+	config := app.config.Ranking
+
+	return store.Ranking{
+		Recency:     config.RecencyWeight,
+		Engagement:  config.EngagementWeight,
+		Affinity:    config.AffinityWeight,
+		TagInterest: config.TagInterestWeight,
+		HalfLife:    config.HalfLife,
+		Window:      config.Window,
+		History:     config.History,
+	}
+}
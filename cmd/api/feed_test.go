@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"social/internal/types"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGetUserPosts(t *testing.T) {
+
+	const (
+		author = int64(1)
+		viewer = int64(2)
+	)
+
+	app := newTestApplication(&fakePostStore{}, &fakeUserStore{
+		users: map[int64]types.User{author: {ID: author}},
+	})
+	timelines := app.store.Timelines.(*fakeTimelineStore)
+
+	r := chi.NewRouter()
+	r.Use(withUser(viewer))
+	r.Route("/users/{userId}", func(r chi.Router) {
+		r.Use(app.userContextMiddleware)
+		r.Get("/posts", app.getUserPostsHandler)
+	})
+
+	tests := []struct {
+		name    string
+		url     string
+		status  int
+		replies bool
+		reposts bool
+	}{
+		{"posts only", "/users/1/posts", http.StatusOK, false, false},
+		{"with the replies", "/users/1/posts?replies=true", http.StatusOK, true, false},
+		{"with the reposts", "/users/1/posts?reposts=true", http.StatusOK, false, true},
+		{"with the replies and reposts", "/users/1/posts?replies=true&reposts=true", http.StatusOK, true, true},
+		{"invalid replies parameter", "/users/1/posts?replies=maybe", http.StatusBadRequest, false, false},
+		{"invalid reposts parameter", "/users/1/posts?reposts=maybe", http.StatusBadRequest, false, false},
+		{"invalid sort", "/users/1/posts?sort=sideways", http.StatusBadRequest, false, false},
+		{"unknown user", "/users/9/posts", http.StatusNotFound, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			*timelines = fakeTimelineStore{}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if timelines.userId != author {
+				t.Errorf("expected the posts of user %d, got %d", author, timelines.userId)
+			}
+			if timelines.include.Replies != tt.replies {
+				t.Errorf("expected replies %v, got %v", tt.replies, timelines.include.Replies)
+			}
+			if timelines.include.Reposts != tt.reposts {
+				t.Errorf("expected reposts %v, got %v", tt.reposts, timelines.include.Reposts)
+			}
+		})
+	}
+}
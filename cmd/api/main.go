@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"social/internal/auth"
+	"social/internal/cache"
 	"social/internal/db"
 	"social/internal/env"
 	"social/internal/mailer"
+	"social/internal/notifications"
+	"social/internal/pubsub"
 	"social/internal/store"
+	"social/internal/timeline"
+	"social/internal/webhooks"
 
 	"log"
+	"time"
 )
 
 func main() {
@@ -33,20 +40,56 @@ func main() {
 		mailer    = mailer.NewSendgrid(ApiKey, FromEmail)
 	)
 
+	switch config := env.Envs.Cache; config.Driver {
+	case "none":
+	case "memory":
+		store.UseCache(cache.NewLRU(config.Size), config.TTL)
+	case "redis":
+		redis, err := cache.NewRedis(config.Redis.Addr, config.Redis.Password, config.Redis.DB, "social:")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer redis.Close()
+		store.UseCache(redis, config.TTL)
+	default:
+		log.Fatalf("unknown cache driver %q", config.Driver)
+	}
+
 	jwtAuthenticator := auth.NewJWTAuthenticator(
 		env.Envs.JWTConfig.Secret,
 		env.Envs.JWTConfig.Issuer,
 		env.Envs.JWTConfig.Issuer,
 	)
 
+	var events pubsub.PubSub = pubsub.NewLocal()
+	if env.Envs.PubSubDriver == "postgres" {
+		events, err = pubsub.NewPostgres(db, env.Envs.DbConfig.Addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	defer events.Close()
+
 	app := &application{
 		config:        env.Envs,
 		store:         store,
 		db:            db,
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
+		permissions:   newPermissionCache(store.Permissions, env.Envs.PermissionsCacheTTL),
+		notifications: notifications.NewService(store.Notifications, events),
+		events:        events,
+		webhooks:      webhooks.NewDispatcher(store.Webhooks, env.Envs.WebhookMaxAttempts, env.Envs.WebhookDisableAfter),
+		timeline:      timeline.NewService(store.Timelines, env.Envs.FanoutThreshold, env.Envs.TimelineBackfill),
 	}
 
+	go app.runAuditLogRetention(context.Background(), time.Hour*24)
+	go app.runPostScheduler(context.Background(), time.Minute)
+	go app.runDeletedPurge(context.Background(), time.Hour*24)
+	go app.runWebhookRetries(context.Background(), time.Second*30)
+	go app.timeline.Run(context.Background())
+
 	mux := app.mount()
 
 	log.Fatal(app.run(mux))
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"social/internal/store"
+)
+
+// getMentionsHandler lists the posts that mention the authenticated user
+func (app *application) getMentionsHandler(w http.ResponseWriter, req *http.Request) {
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	posts, err := app.store.Mentions.GetPosts(req.Context(), viewer, fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
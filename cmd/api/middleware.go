@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"social/internal/auth"
+	"social/internal/store"
 	"social/internal/types"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -31,6 +36,28 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		token := parts[1]
 
+		ctx := req.Context()
+
+		// api keys are accepted as an alternative credential to user jwts
+		if auth.IsAPIKey(token) {
+			user, key, err := app.authenticateAPIKey(ctx, token)
+			if err != nil {
+				app.unAuthorizedError(w, req, err)
+				return
+			}
+
+			if isSuspended(user) {
+				app.suspendedResponse(w, req, user)
+				return
+			}
+
+			ctx = context.WithValue(ctx, userCtx, user)
+			ctx = context.WithValue(ctx, apiKeyCtx, key)
+
+			next.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unAuthorizedError(w, req, err)
@@ -45,14 +72,26 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := req.Context()
-
 		user, err := app.store.Users.GetById(ctx, userID)
 		if err != nil {
 			app.unAuthorizedError(w, req, err)
 			return
 		}
 
+		// tokens issued before a forced logout are no longer valid
+		if user.TokensRevokedAt != nil {
+			issuedAt, err := claims.GetIssuedAt()
+			if err != nil || issuedAt == nil || issuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second)) {
+				app.unAuthorizedError(w, req, fmt.Errorf("token has been revoked"))
+				return
+			}
+		}
+
+		if isSuspended(user) {
+			app.suspendedResponse(w, req, user)
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
 
 		next.ServeHTTP(w, req.WithContext(ctx))
@@ -60,6 +99,80 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+func (app *application) authenticateAPIKey(ctx context.Context, token string) (*types.User, *types.ApiKey, error) {
+
+	prefix, err := auth.ParseAPIKeyPrefix(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := app.store.ApiKeys.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !auth.CompareAPIKey(token, key.KeyHash) {
+		return nil, nil, fmt.Errorf("invalid api key")
+	}
+
+	user, err := app.store.Users.GetById(ctx, key.UserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := app.store.ApiKeys.Touch(ctx, key.ID); err != nil {
+		log.Println("error updating api key last usage: ", err.Error())
+	}
+
+	return user, key, nil
+}
+
+// requireScope only restricts requests authenticated with an api key,
+// keys created without any scope have the full access of their owner
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			key := getApiKeyFromCtx(req)
+			if key == nil || len(key.Scopes) == 0 || slices.Contains(key.Scopes, scope) {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			app.forbiddenResponse(w, req)
+		})
+	}
+}
+
+// optionalAuthMiddleware authenticates the requests carrying credentials and lets anonymous ones through
+func (app *application) optionalAuthMiddleware(next http.Handler) http.Handler {
+
+	authenticated := app.AuthTokenMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		authenticated.ServeHTTP(w, req)
+	})
+}
+
+// sessionOnlyMiddleware rejects requests authenticated with an api key
+func (app *application) sessionOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		if getApiKeyFromCtx(req) != nil {
+			app.forbiddenResponse(w, req)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
 func (app *application) BasciAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -99,7 +212,45 @@ func (app *application) BasciAuthMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+func isSuspended(user *types.User) bool {
+	return user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now())
+}
+
+// getViewer describes the authenticated user for the visibility rules of the store
+func (app *application) getViewer(req *http.Request) (store.Viewer, error) {
+
+	user := getUserFromCtx(req)
+
+	moderator, err := app.hasPermission(req.Context(), user, PermModerateReports)
+	if err != nil {
+		return store.Viewer{}, err
+	}
+
+	return store.Viewer{
+		ID:        user.ID,
+		Moderator: moderator,
+	}, nil
+}
+
+// canViewPost applies the visibility rules of the viewer to a single post
+func (app *application) canViewPost(ctx context.Context, viewer store.Viewer, post *types.Post) (bool, error) {
+
+	var rel store.Relationship
+
+	if post.UserId != viewer.ID {
+		var err error
+		rel, err = app.store.Users.GetRelationship(ctx, viewer.ID, post.UserId)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return viewer.CanViewPost(post, rel), nil
+}
+
+// checkPostOwnership lets the owner of the post through,
+// other users need the given permission to act on it
+func (app *application) checkPostOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 
 		user := getUserFromCtx(req)
@@ -111,7 +262,7 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
-		allowed, err := app.checkRolePrecedence(req.Context(), user, requiredRole)
+		allowed, err := app.hasPermission(req.Context(), user, permission)
 		if err != nil {
 			app.internalServerError(w, req, err)
 			return
@@ -119,18 +270,9 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 
 		if !allowed {
 			app.forbiddenResponse(w, req)
+			return
 		}
 
 		next.ServeHTTP(w, req)
 	})
 }
-
-func (app *application) checkRolePrecedence(ctx context.Context, user *types.User, roleName string) (bool, error) {
-	role, err := app.store.Roles.GetByName(ctx, roleName)
-	if err != nil {
-		return false, err
-	}
-
-	return user.Role.Level >= role.Level, nil
-
-}
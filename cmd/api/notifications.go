@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"social/internal/notifications"
+	"social/internal/pubsub"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (app *application) getNotificationsHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	nq := store.NotificationQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	nq, err := nq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(nq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	groups, err := app.store.Notifications.GetGrouped(req.Context(), user.ID, nq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	for i := range groups {
+		groups[i].Message = notifications.Message(groups[i])
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, groups); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	count, err := app.store.Notifications.CountUnread(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]int{"unread": count}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	notificationId, err := strconv.ParseInt(chi.URLParam(req, "notificationId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := app.store.Notifications.MarkRead(req.Context(), user.ID, notificationId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "notification marked as read"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	if err := app.store.Notifications.MarkAllRead(req.Context(), user.ID); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "notifications marked as read"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	preferences, err := app.store.Notifications.GetPreferences(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, preferences); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// updateNotificationPreferencesHandler takes a map of notification types to whether they are received
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	ctx := req.Context()
+
+	var payload map[string]bool
+
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	for notificationType := range payload {
+		if !slices.Contains(types.NotificationTypes, notificationType) {
+			app.badRequestResponse(w, req, fmt.Errorf("unknown notification type %s", notificationType))
+			return
+		}
+	}
+
+	for notificationType, enabled := range payload {
+		if err := app.store.Notifications.SetPreference(ctx, user.ID, notificationType, enabled); err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+	}
+
+	preferences, err := app.store.Notifications.GetPreferences(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, preferences); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// notifyPostPublished notifies the users mentioned by a published post, except the ones in
+// previous who were already notified, and the author of the quoted post, the followers and the webhooks
+// on first publication
+func (app *application) notifyPostPublished(ctx context.Context, post *types.Post, previous []int64) {
+
+	if post.Status != types.PostPublished {
+		return
+	}
+
+	if previous == nil {
+		app.publishEvent(ctx, pubsub.AuthorTopic(post.UserId), EventPostPublished, streamRef{PostId: post.ID, UserId: post.UserId})
+		app.webhooks.Publish(ctx, types.WebhookPostCreated, []int64{post.UserId}, post)
+		app.timeline.PostPublished(ctx, post.ID)
+	}
+
+	for _, userId := range post.MentionedUserIds {
+		if !slices.Contains(previous, userId) && app.canNotifyPost(ctx, post, userId) {
+			app.notifications.Publish(ctx, userId, post.UserId, types.NotificationMention, "post", post.ID)
+		}
+	}
+
+	if previous == nil && post.QuotedPost != nil && app.canNotifyPost(ctx, post, post.QuotedPost.UserId) {
+		app.notifications.Publish(ctx, post.QuotedPost.UserId, post.UserId, types.NotificationQuote, "post", post.ID)
+	}
+}
+
+// notifyCommentMentions notifies the users mentioned by a comment, except the ones in previous
+// who were already notified, when they can see both the post and the comment
+func (app *application) notifyCommentMentions(ctx context.Context, post *types.Post, comment *types.Comment, previous []int64) {
+	for _, userId := range comment.MentionedUserIds {
+		if !slices.Contains(previous, userId) && app.canNotifyComment(ctx, post, comment, userId) {
+			app.notifications.Publish(ctx, userId, comment.UserId, types.NotificationMention, "comment", comment.ID)
+		}
+	}
+}
+
+// canNotifyComment is canNotifyPost for a comment of the post. errors count as a no
+func (app *application) canNotifyComment(ctx context.Context, post *types.Post, comment *types.Comment, userId int64) bool {
+
+	if !app.canNotifyPost(ctx, post, userId) {
+		return false
+	}
+
+	if comment.UserId == userId {
+		return true
+	}
+
+	rel, err := app.store.Users.GetRelationship(ctx, userId, comment.UserId)
+	if err != nil {
+		log.Printf("error checking if user %d can view comment %d: %v", userId, comment.ID, err)
+		return false
+	}
+
+	return store.Viewer{ID: userId}.CanViewComment(comment, rel)
+}
+
+// canNotifyPost reports whether the user can see the post, a notification about a post the user
+// can not open would still give away that it exists and who wrote it. errors count as a no
+func (app *application) canNotifyPost(ctx context.Context, post *types.Post, userId int64) bool {
+
+	// the post of a create or an edit does not carry the privacy of its author
+	author, err := app.store.Users.GetById(ctx, post.UserId)
+	if err != nil {
+		log.Printf("error loading the author of post %d: %v", post.ID, err)
+		return false
+	}
+
+	check := *post
+	check.User.IsPrivate = author.IsPrivate
+
+	allowed, err := app.canViewPost(ctx, store.Viewer{ID: userId}, &check)
+	if err != nil {
+		log.Printf("error checking if user %d can view post %d: %v", userId, post.ID, err)
+		return false
+	}
+
+	return allowed
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	PermUpdateAnyPost    = "posts:update:any"
+	PermDeleteAnyPost    = "posts:delete:any"
+	PermModerateComments = "comments:moderate"
+	PermManageRoles      = "roles:manage"
+)
+
+// permissionCache keeps the role -> permissions mapping in memory,
+// it is reloaded from the database once the ttl is over or after an invalidation
+type permissionCache struct {
+	mu       sync.RWMutex
+	store    store.IPermissionStore
+	ttl      time.Duration
+	loadedAt time.Time
+	roles    map[int64][]string
+}
+
+func newPermissionCache(store store.IPermissionStore, ttl time.Duration) *permissionCache {
+	return &permissionCache{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+func (cache *permissionCache) get(ctx context.Context, roleId int64) ([]string, error) {
+
+	cache.mu.RLock()
+	if cache.roles != nil && time.Since(cache.loadedAt) < cache.ttl {
+		permissions := cache.roles[roleId]
+		cache.mu.RUnlock()
+		return permissions, nil
+	}
+	cache.mu.RUnlock()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// another request may have reloaded the cache while we were waiting for the lock
+	if cache.roles == nil || time.Since(cache.loadedAt) >= cache.ttl {
+		roles, err := cache.store.GetRolePermissions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cache.roles = roles
+		cache.loadedAt = time.Now()
+	}
+
+	return cache.roles[roleId], nil
+}
+
+func (cache *permissionCache) invalidate() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.roles = nil
+}
+
+func (app *application) hasPermission(ctx context.Context, user *types.User, permission string) (bool, error) {
+
+	permissions, err := app.permissions.get(ctx, user.Role.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(permissions, permission), nil
+}
+
+func (app *application) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			user := getUserFromCtx(req)
+
+			allowed, err := app.hasPermission(req.Context(), user, permission)
+			if err != nil {
+				app.internalServerError(w, req, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, req)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func (app *application) getRolesHandler(w http.ResponseWriter, req *http.Request) {
+
+	ctx := req.Context()
+
+	roles, err := app.store.Roles.GetAll(ctx)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	for i := range roles {
+		permissions, err := app.permissions.get(ctx, roles[i].ID)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+		roles[i].Permissions = permissions
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getPermissionsHandler(w http.ResponseWriter, req *http.Request) {
+
+	permissions, err := app.store.Permissions.GetAll(req.Context())
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, permissions); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) grantPermissionHandler(w http.ResponseWriter, req *http.Request) {
+
+	roleId, err := strconv.ParseInt(chi.URLParam(req, "roleId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	permission := chi.URLParam(req, "permission")
+
+	if err := app.store.Permissions.Grant(req.Context(), roleId, permission); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.permissions.invalidate()
+
+	user := getUserFromCtx(req)
+	app.audit(req, user.ID, AuditPermissionGrant, "role", roleId, nil, map[string]string{"permission": permission})
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "permission granted successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) revokePermissionHandler(w http.ResponseWriter, req *http.Request) {
+
+	roleId, err := strconv.ParseInt(chi.URLParam(req, "roleId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	permission := chi.URLParam(req, "permission")
+
+	if err := app.store.Permissions.Revoke(req.Context(), roleId, permission); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.permissions.invalidate()
+
+	user := getUserFromCtx(req)
+	app.audit(req, user.ID, AuditPermissionRevoke, "role", roleId, map[string]string{"permission": permission}, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "permission revoked successfully"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/store"
+	"social/internal/types"
+)
+
+// pinPostHandler pins a published post of the user to the top of their profile
+func (app *application) pinPostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if post.UserId != user.ID {
+		app.forbiddenResponse(w, req)
+		return
+	}
+
+	if post.Status != types.PostPublished {
+		app.conflictError(w, req, fmt.Errorf("only published posts can be pinned"))
+		return
+	}
+
+	if err := app.store.Posts.Pin(req.Context(), post.ID, user.ID, app.config.MaxPinnedPosts); err != nil {
+		switch {
+		case errors.Is(err, store.ErrPinLimit):
+			app.conflictError(w, req, fmt.Errorf("at most %d posts can be pinned", app.config.MaxPinnedPosts))
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, fmt.Errorf("post already pinned"))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "post pinned"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) unpinPostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if post.UserId != user.ID {
+		app.forbiddenResponse(w, req)
+		return
+	}
+
+	if err := app.store.Posts.Unpin(req.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, fmt.Errorf("post is not pinned"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "post unpinned"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"social/internal/types"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestPinPost(t *testing.T) {
+
+	const (
+		author   = int64(1)
+		stranger = int64(2)
+	)
+
+	posts := &fakePostStore{
+		posts: map[int64]types.Post{
+			1: {ID: 1, UserId: author, Visibility: types.VisibilityPublic, Status: types.PostPublished},
+			2: {ID: 2, UserId: author, Visibility: types.VisibilityPublic, Status: types.PostPublished},
+			3: {ID: 3, UserId: author, Visibility: types.VisibilityPublic, Status: types.PostPublished},
+			4: {ID: 4, UserId: author, Visibility: types.VisibilityPublic, Status: types.PostDraft},
+		},
+		pinned: map[int64]bool{},
+	}
+
+	app := newTestApplication(posts, &fakeUserStore{})
+	app.config.MaxPinnedPosts = 2
+
+	request := func(method string, userId int64, postId string) int {
+
+		r := chi.NewRouter()
+		r.Use(withUser(userId))
+		r.Route("/posts/{postId}", func(r chi.Router) {
+			r.Use(app.postsContextMiddleware)
+			r.Put("/pin", app.pinPostHandler)
+			r.Delete("/pin", app.unpinPostHandler)
+		})
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(method, "/posts/"+postId+"/pin", nil))
+
+		return rr.Code
+	}
+
+	// the steps run in order against the same pins
+	steps := []struct {
+		name   string
+		method string
+		user   int64
+		postId string
+		status int
+	}{
+		{"pin a post", http.MethodPut, author, "1", http.StatusOK},
+		{"pin a pinned post", http.MethodPut, author, "1", http.StatusConflict},
+		{"pin a post of another user", http.MethodPut, stranger, "2", http.StatusForbidden},
+		{"pin a draft", http.MethodPut, author, "4", http.StatusConflict},
+		{"pin a second post", http.MethodPut, author, "2", http.StatusOK},
+		{"pin over the limit", http.MethodPut, author, "3", http.StatusConflict},
+		{"unpin a post of another user", http.MethodDelete, stranger, "1", http.StatusForbidden},
+		{"unpin a post", http.MethodDelete, author, "1", http.StatusOK},
+		{"unpin a post that is not pinned", http.MethodDelete, author, "1", http.StatusNotFound},
+		{"pin once below the limit again", http.MethodPut, author, "3", http.StatusOK},
+	}
+
+	for _, step := range steps {
+		if status := request(step.method, step.user, step.postId); status != step.status {
+			t.Errorf("%s: expected status %d, got %d", step.name, step.status, status)
+		}
+	}
+}
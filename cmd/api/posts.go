@@ -2,14 +2,16 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 
 	"net/http"
+	"social/internal/content"
 	"social/internal/store"
 	"social/internal/types"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -32,11 +34,65 @@ func (app *application) createPostHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	user := getUserFromCtx(req)
+
+	explicitTags, err := content.NormalizeTags(payload.Tags)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	tags, err := content.MergeTags(explicitTags, payload.Content)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
 	post := &types.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		UserId:  1,
-		Tags:    payload.Tags,
+		Title:        payload.Title,
+		Content:      payload.Content,
+		UserId:       user.ID,
+		Tags:         tags,
+		ExplicitTags: explicitTags,
+		Visibility:   payload.Visibility,
+		Status:       types.PostPublished,
+	}
+
+	// a post with a publication time waits for the scheduler, a draft for its author
+	switch {
+	case payload.PublishAt != nil:
+		publishAt, err := parsePublishAt(*payload.PublishAt)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+		value := publishAt.Format(time.RFC3339)
+		post.Status = types.PostScheduled
+		post.PublishAt = &value
+	case payload.Draft:
+		post.Status = types.PostDraft
+	}
+
+	if payload.QuotePostId != nil {
+		viewer, err := app.getViewer(req)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		quoted, err := app.getQuotedPost(req.Context(), viewer, *payload.QuotePostId)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		if quoted == nil || !isShareable(quoted) {
+			app.badRequestResponse(w, req, fmt.Errorf("only public posts can be quoted"))
+			return
+		}
+
+		post.QuotePostId = &quoted.ID
+		post.QuotedPost = quoted
 	}
 
 	ctx := req.Context()
@@ -46,6 +102,8 @@ func (app *application) createPostHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	app.notifyPostPublished(ctx, post, nil)
+
 	if err := app.JsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, req, err)
 		return
@@ -54,10 +112,14 @@ func (app *application) createPostHandler(w http.ResponseWriter, req *http.Reque
 
 func (app *application) deletePostHandler(w http.ResponseWriter, req *http.Request) {
 
+	user := getUserFromCtx(req)
 	post := getPostFromCtx(req)
 
-	if err := app.store.Posts.Delete(req.Context(), post.ID); err != nil {
+	// the version checked by requirePostIfMatch, an edit made since then wins over the delete
+	if err := app.store.Posts.Delete(req.Context(), post.ID, &post.Version, user.ID); err != nil {
 		switch {
+		case errors.Is(err, store.ErrVersionMismatch):
+			app.preconditionFailedError(w, req, err)
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, req, err)
 		default:
@@ -66,6 +128,8 @@ func (app *application) deletePostHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	app.audit(req, user.ID, AuditPostDelete, "post", post.ID, post, nil)
+
 	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "post deleted successfully"}); err != nil {
 		app.internalServerError(w, req, err)
 		return
@@ -76,7 +140,13 @@ func (app *application) getPostHandler(w http.ResponseWriter, req *http.Request)
 
 	post := getPostFromCtx(req)
 
-	comments, err := app.store.Comments.GetByPostId(req.Context(), post.ID)
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostId(req.Context(), post.ID, viewer)
 	if err != nil {
 		app.internalServerError(w, req, err)
 		return
@@ -84,6 +154,33 @@ func (app *application) getPostHandler(w http.ResponseWriter, req *http.Request)
 
 	post.Comments = comments
 
+	post.Bookmarked, err = app.store.Bookmarks.Exists(req.Context(), viewer.ID, post.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if post.QuotePostId != nil {
+		post.QuotedPost, err = app.getQuotedPost(req.Context(), viewer, *post.QuotePostId)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+	}
+
+	etag, err := postETag(post)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+
+	if header := req.Header.Get("If-None-Match"); header != "" && matchETag(header, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	if err := app.JsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, req, err)
 		return
@@ -92,7 +189,9 @@ func (app *application) getPostHandler(w http.ResponseWriter, req *http.Request)
 
 func (app *application) updatePostHandler(w http.ResponseWriter, req *http.Request) {
 
+	user := getUserFromCtx(req)
 	post := getPostFromCtx(req)
+	before := *post
 
 	var payload types.UpdatePostPayload
 
@@ -108,15 +207,26 @@ func (app *application) updatePostHandler(w http.ResponseWriter, req *http.Reque
 
 	if payload.Content != nil {
 		post.Content = *payload.Content
+
+		// the hashtags follow the new content, the tags given by the author are kept
+		tags, err := content.MergeTags(post.ExplicitTags, post.Content)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+		post.Tags = tags
 	}
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
 
 	if err := app.store.Posts.Update(req.Context(), post); err != nil {
 		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			app.notFoundError(w, req, err)
+		case errors.Is(err, store.ErrVersionMismatch):
+			app.preconditionFailedError(w, req, err)
 			return
 		default:
 			app.internalServerError(w, req, err)
@@ -124,6 +234,17 @@ func (app *application) updatePostHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	// edits of someone else's post are only possible through a moderation permission
+	if post.UserId != user.ID {
+		app.audit(req, user.ID, AuditPostModerate, "post", post.ID, before, post)
+	}
+
+	app.notifyPostPublished(req.Context(), post, before.MentionedUserIds)
+
+	if etag, err := postETag(post); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+
 	if err := app.JsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, req, err)
 		return
@@ -155,6 +276,23 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		viewer, err := app.getViewer(req)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		allowed, err := app.canViewPost(ctx, viewer, post)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		if !allowed {
+			app.notFoundError(w, req, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, req.WithContext(ctx))
 
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"social/internal/store"
+	"social/internal/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fakePostStore struct {
+	store.IPostStore
+	posts  map[int64]types.Post
+	pinned map[int64]bool
+}
+
+func (s *fakePostStore) GetPostById(ctx context.Context, postId int64) (*types.Post, error) {
+	post, ok := s.posts[postId]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &post, nil
+}
+
+func (s *fakePostStore) Pin(ctx context.Context, postId int64, userId int64, limit int) error {
+	if s.pinned[postId] {
+		return store.ErrConflict
+	}
+	if len(s.pinned) >= limit {
+		return store.ErrPinLimit
+	}
+	s.pinned[postId] = true
+	return nil
+}
+
+func (s *fakePostStore) Unpin(ctx context.Context, postId int64, userId int64) error {
+	if !s.pinned[postId] {
+		return store.ErrNotFound
+	}
+	delete(s.pinned, postId)
+	return nil
+}
+
+type fakeUserStore struct {
+	store.IUserStore
+	users     map[int64]types.User
+	followers map[int64][]int64
+}
+
+func (s *fakeUserStore) GetById(ctx context.Context, userId int64) (*types.User, error) {
+	user, ok := s.users[userId]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *fakeUserStore) GetRelationship(ctx context.Context, viewerId int64, userId int64) (store.Relationship, error) {
+	rel := store.Relationship{}
+	for _, id := range s.followers[userId] {
+		if id == viewerId {
+			rel.Following = true
+		}
+	}
+	return rel, nil
+}
+
+type fakeCommentStore struct {
+	store.ICommentStore
+}
+
+func (s *fakeCommentStore) GetByPostId(ctx context.Context, postId int64, viewer store.Viewer) ([]types.Comment, error) {
+	return []types.Comment{}, nil
+}
+
+type fakeBookmarkStore struct {
+	store.IBookmarkStore
+	bookmarked bool
+}
+
+func (s *fakeBookmarkStore) Exists(ctx context.Context, userId int64, postId int64) (bool, error) {
+	return s.bookmarked, nil
+}
+
+type fakeTimelineStore struct {
+	store.ITimelineStore
+	userId  int64
+	include store.ProfileInclude
+}
+
+func (s *fakeTimelineStore) GetProfile(ctx context.Context, viewer store.Viewer, userId int64, fq store.PaginatedFeedQuery, include store.ProfileInclude) ([]types.PostWithMetadata, error) {
+	s.userId = userId
+	s.include = include
+	return []types.PostWithMetadata{}, nil
+}
+
+type fakePermissionStore struct {
+	store.IPermissionStore
+}
+
+func (s *fakePermissionStore) GetRolePermissions(ctx context.Context) (map[int64][]string, error) {
+	return map[int64][]string{}, nil
+}
+
+func newTestApplication(posts *fakePostStore, users *fakeUserStore) *application {
+	permissions := &fakePermissionStore{}
+
+	return &application{
+		store: &store.Storage{
+			Posts:       posts,
+			Users:       users,
+			Comments:    &fakeCommentStore{},
+			Bookmarks:   &fakeBookmarkStore{},
+			Timelines:   &fakeTimelineStore{},
+			Permissions: permissions,
+		},
+		permissions: newPermissionCache(permissions, time.Minute),
+	}
+}
+
+// withUser authenticates every request of a test router as the given user
+func withUser(userId int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := context.WithValue(req.Context(), userCtx, &types.User{ID: userId})
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+// getPostAs runs GET /posts/{postId} through postsContextMiddleware for the given user
+func getPostAs(app *application, userId int64, postId string) *httptest.ResponseRecorder {
+
+	r := chi.NewRouter()
+	r.Use(withUser(userId))
+	r.Route("/posts/{postId}", func(r chi.Router) {
+		r.Use(app.postsContextMiddleware)
+		r.Get("/", app.getPostHandler)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/posts/"+postId+"/", nil))
+
+	return rr
+}
+
+func TestGetPostVisibility(t *testing.T) {
+
+	const (
+		author    = int64(1)
+		follower  = int64(2)
+		stranger  = int64(3)
+		mentioned = int64(4)
+	)
+
+	posts := &fakePostStore{posts: map[int64]types.Post{
+		1: {ID: 1, UserId: author, Visibility: types.VisibilityPublic},
+		2: {ID: 2, UserId: author, Visibility: types.VisibilityFollowers},
+		3: {ID: 3, UserId: author, Visibility: types.VisibilityMentioned, MentionedUserIds: []int64{mentioned}},
+		4: {ID: 4, UserId: author, Visibility: types.VisibilityPrivate},
+	}}
+
+	users := &fakeUserStore{followers: map[int64][]int64{
+		author: {follower},
+	}}
+
+	app := newTestApplication(posts, users)
+
+	tests := []struct {
+		name   string
+		viewer int64
+		postId string
+		status int
+	}{
+		{"public post for a non-follower", stranger, "1", http.StatusOK},
+		{"followers-only post for a non-follower", stranger, "2", http.StatusNotFound},
+		{"followers-only post for a follower", follower, "2", http.StatusOK},
+		{"followers-only post for its author", author, "2", http.StatusOK},
+		{"mentioned-only post for a follower", follower, "3", http.StatusNotFound},
+		{"mentioned-only post for a mentioned user", mentioned, "3", http.StatusOK},
+		{"private post for a follower", follower, "4", http.StatusNotFound},
+		{"private post for its author", author, "4", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := getPostAs(app, tt.viewer, tt.postId)
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+		})
+	}
+}
+
+func TestPostETag(t *testing.T) {
+
+	const author = int64(1)
+
+	posts := &fakePostStore{posts: map[int64]types.Post{
+		1: {ID: 1, UserId: author, Version: 3, Visibility: types.VisibilityPublic},
+	}}
+
+	bookmarks := &fakeBookmarkStore{}
+
+	app := newTestApplication(posts, &fakeUserStore{})
+	app.store.Bookmarks = bookmarks
+
+	r := chi.NewRouter()
+	r.Use(withUser(author))
+	r.Route("/posts/{postId}", func(r chi.Router) {
+		r.Use(app.postsContextMiddleware)
+		r.Get("/", app.getPostHandler)
+		r.With(app.requirePostIfMatch).Patch("/", app.updatePostHandler)
+	})
+
+	request := func(method, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/posts/1/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request(http.MethodGet, "", "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `"3-`) {
+		t.Fatalf("expected 200 with a version 3 etag, got %d %q", rr.Code, etag)
+	}
+
+	if rr := request(http.MethodGet, "If-None-Match", etag); rr.Code != http.StatusNotModified {
+		t.Errorf("get with the current etag: expected 304, got %d", rr.Code)
+	}
+
+	// bookmarking does not change the version but changes the response
+	bookmarks.bookmarked = true
+	if rr := request(http.MethodGet, "If-None-Match", etag); rr.Code != http.StatusOK {
+		t.Errorf("get after a bookmark: expected 200, got %d", rr.Code)
+	}
+
+	if rr := request(http.MethodPatch, "", ""); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("patch without If-Match: expected 428, got %d", rr.Code)
+	}
+
+	if rr := request(http.MethodPatch, "If-Match", `"2-0123456789abcdef"`); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("patch with an old etag: expected 412, got %d", rr.Code)
+	}
+
+	// the hash is not compared on writes, any etag of the current version passes
+	if rr := request(http.MethodPatch, "If-Match", etag); rr.Code == http.StatusPreconditionFailed || rr.Code == http.StatusPreconditionRequired {
+		t.Errorf("patch with the current etag: expected the precondition to pass, got %d", rr.Code)
+	}
+}
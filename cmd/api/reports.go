@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const PermModerateReports = "reports:moderate"
+
+type reportKey string
+
+const reportCtx reportKey = "report"
+
+func (app *application) createReportHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	var payload types.CreateReportPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	ctx := req.Context()
+
+	// the reported content has to be visible to the reporter
+	authorId, err := app.getReportTargetAuthor(req, payload.TargetType, payload.TargetId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if authorId == user.ID {
+		app.badRequestResponse(w, req, fmt.Errorf("you can not report your own content"))
+		return
+	}
+
+	report := &types.Report{
+		ReporterId:   user.ID,
+		TargetType:   payload.TargetType,
+		TargetId:     payload.TargetId,
+		TargetUserId: authorId,
+		Reason:       payload.Reason,
+		Details:      payload.Details,
+	}
+
+	if err := app.store.Reports.Create(ctx, report); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, fmt.Errorf("you already reported this %s", payload.TargetType))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusCreated, report); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getReportTargetAuthor(req *http.Request, targetType string, targetId int64) (int64, error) {
+
+	ctx := req.Context()
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		return 0, err
+	}
+
+	switch targetType {
+	case "post":
+		post, err := app.store.Posts.GetPostById(ctx, targetId)
+		if err != nil {
+			return 0, err
+		}
+		allowed, err := app.canViewPost(ctx, viewer, post)
+		if err != nil {
+			return 0, err
+		}
+		if !allowed {
+			return 0, store.ErrNotFound
+		}
+		return post.UserId, nil
+	case "comment":
+		comment, err := app.store.Comments.GetById(ctx, targetId)
+		if err != nil {
+			return 0, err
+		}
+		rel, err := app.store.Users.GetRelationship(ctx, viewer.ID, comment.UserId)
+		if err != nil {
+			return 0, err
+		}
+		if !viewer.CanViewComment(comment, rel) {
+			return 0, store.ErrNotFound
+		}
+		return comment.UserId, nil
+	default:
+		return 0, fmt.Errorf("unknown report target %s", targetType)
+	}
+}
+
+func (app *application) getReportsHandler(w http.ResponseWriter, req *http.Request) {
+
+	fq := store.ReportQuery{
+		Limit:  50,
+		Offset: 0,
+		Status: "open",
+	}
+
+	fq, err := fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	reports, err := app.store.Reports.GetAll(req.Context(), fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, reports); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getReportHandler(w http.ResponseWriter, req *http.Request) {
+
+	report := getReportFromCtx(req)
+
+	if err := app.JsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) resolveReportHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	report := getReportFromCtx(req)
+
+	var payload types.ResolveReportPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if report.Status != "open" {
+		app.conflictError(w, req, fmt.Errorf("report is already %s", report.Status))
+		return
+	}
+
+	if payload.Action != "dismiss" && (report.TargetUserId == 0 || report.TargetDeleted) {
+		app.conflictError(w, req, fmt.Errorf("reported %s no longer exists", report.TargetType))
+		return
+	}
+
+	ctx := req.Context()
+
+	// moderators can only act on users below their own role
+	if payload.Action != "dismiss" {
+		target, err := app.store.Users.GetAnyById(ctx, report.TargetUserId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		if target.Role.Level >= user.Role.Level {
+			app.forbiddenResponse(w, req)
+			return
+		}
+	}
+
+	before := *report
+
+	report.Status = "resolved"
+	if payload.Action == "dismiss" {
+		report.Status = "dismissed"
+	}
+	report.Resolution = payload.Action
+	report.Note = payload.Note
+	report.ResolvedBy = &user.ID
+
+	// the action only runs once the report is claimed, a second moderator gets a conflict
+	err := app.store.Reports.Resolve(ctx, report, func(ctx context.Context) error {
+		return app.applyModerationAction(ctx, user.ID, report, payload)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, fmt.Errorf("report was resolved by someone else"))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, "moderation."+payload.Action, report.TargetType, report.TargetId, before, report)
+
+	if err := app.JsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) applyModerationAction(ctx context.Context, moderatorId int64, report *types.Report, payload types.ResolveReportPayload) error {
+
+	switch payload.Action {
+	case "dismiss":
+		return nil
+	case "hide":
+		if report.TargetType == "post" {
+			return app.store.Posts.SetHidden(ctx, report.TargetId, true)
+		}
+		return app.store.Comments.SetHidden(ctx, report.TargetId, true)
+	case "delete":
+		if report.TargetType == "post" {
+			return app.store.Posts.Delete(ctx, report.TargetId, nil, moderatorId)
+		}
+		return app.store.Comments.Delete(ctx, report.TargetId, moderatorId)
+	case "warn":
+		return app.store.Users.Warn(ctx, report.TargetUserId)
+	case "suspend":
+		until := time.Now().Add(time.Hour * 24 * time.Duration(payload.SuspendDays))
+		return app.store.Users.Suspend(ctx, report.TargetUserId, until)
+	default:
+		return fmt.Errorf("unknown moderation action %s", payload.Action)
+	}
+}
+
+func (app *application) reportContextMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		reportId, err := strconv.ParseInt(chi.URLParam(req, "reportId"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+
+		ctx := req.Context()
+
+		report, err := app.store.Reports.GetById(ctx, reportId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, reportCtx, report)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func getReportFromCtx(req *http.Request) *types.Report {
+	report, ok := req.Context().Value(reportCtx).(*types.Report)
+	if !ok {
+		log.Println("error: failed to get report from context")
+		return nil
+	}
+	return report
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/pubsub"
+	"social/internal/store"
+	"social/internal/types"
+)
+
+// isShareable reports whether a post can be reposted or quoted, only public posts
+// of public accounts can be so sharing never widens the audience of a post
+func isShareable(post *types.Post) bool {
+	return post.Status == types.PostPublished &&
+		post.Visibility == types.VisibilityPublic &&
+		!post.User.IsPrivate
+}
+
+func (app *application) repostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if !isShareable(post) {
+		app.badRequestResponse(w, req, fmt.Errorf("only public posts can be reposted"))
+		return
+	}
+
+	if err := app.store.Posts.Repost(req.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, fmt.Errorf("post already reposted"))
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.timeline.Reposted(req.Context(), user.ID, post.ID)
+	app.publishEvent(req.Context(), pubsub.AuthorTopic(user.ID), EventPostReposted, streamRef{PostId: post.ID, UserId: user.ID})
+	app.notifications.Publish(req.Context(), post.UserId, user.ID, types.NotificationRepost, "post", post.ID)
+
+	if err := app.JsonResponse(w, http.StatusCreated, map[string]string{"message": "post reposted"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) unrepostHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	post := getPostFromCtx(req)
+
+	if err := app.store.Posts.Unrepost(req.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.timeline.Unreposted(req.Context(), user.ID, post.ID)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "repost removed"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// getQuotedPost loads the post quoted by another one, nil when the viewer can not see it anymore
+func (app *application) getQuotedPost(ctx context.Context, viewer store.Viewer, quotePostId int64) (*types.Post, error) {
+
+	quoted, err := app.store.Posts.GetPostById(ctx, quotePostId)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	allowed, err := app.canViewPost(ctx, viewer, quoted)
+	if err != nil || !allowed {
+		return nil, err
+	}
+
+	return quoted, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/content"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+)
+
+// fieldChange is a single field that differs between two versions of a post
+type fieldChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type postDiff struct {
+	PostId     int64              `json:"post_id"`
+	From       int                `json:"from"`
+	To         int                `json:"to"`
+	Title      *fieldChange       `json:"title,omitempty"`
+	Visibility *fieldChange       `json:"visibility,omitempty"`
+	Content    []content.DiffLine `json:"content"`
+}
+
+// revisionsAccessMiddleware keeps the history of a post to its author and the moderators,
+// older versions may hold text or a visibility the author has since taken back
+func (app *application) revisionsAccessMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		post := getPostFromCtx(req)
+
+		viewer, err := app.getViewer(req)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+
+		if post.UserId != viewer.ID && !viewer.Moderator {
+			app.forbiddenResponse(w, req)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, req *http.Request) {
+
+	post := getPostFromCtx(req)
+
+	revisions, err := app.store.Revisions.GetByPostId(req.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// getPostDiffHandler compares the versions given by ?from= and ?to=, to defaults to the current version
+func (app *application) getPostDiffHandler(w http.ResponseWriter, req *http.Request) {
+
+	post := getPostFromCtx(req)
+	qs := req.URL.Query()
+
+	from, err := strconv.Atoi(qs.Get("from"))
+	if err != nil {
+		app.badRequestResponse(w, req, fmt.Errorf("from must be a version number"))
+		return
+	}
+
+	to := post.Version
+	if value := qs.Get("to"); value != "" {
+		to, err = strconv.Atoi(value)
+		if err != nil {
+			app.badRequestResponse(w, req, fmt.Errorf("to must be a version number"))
+			return
+		}
+	}
+
+	before, err := app.getPostVersion(req, post, from)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	after, err := app.getPostVersion(req, post, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	diff := postDiff{
+		PostId:  post.ID,
+		From:    from,
+		To:      to,
+		Content: content.DiffLines(before.Content, after.Content),
+	}
+
+	if before.Title != after.Title {
+		diff.Title = &fieldChange{Before: before.Title, After: after.Title}
+	}
+	if before.Visibility != after.Visibility {
+		diff.Visibility = &fieldChange{Before: before.Visibility, After: after.Visibility}
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, diff); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// getPostVersion returns the post as it was at the given version, the current version comes from the post itself
+func (app *application) getPostVersion(req *http.Request, post *types.Post, version int) (*types.PostRevision, error) {
+
+	if version == post.Version {
+		return &types.PostRevision{
+			PostId:     post.ID,
+			Version:    post.Version,
+			Title:      post.Title,
+			Content:    post.Content,
+			Visibility: post.Visibility,
+			CreatedAt:  post.UpdatedAt,
+		}, nil
+	}
+
+	return app.store.Revisions.GetByVersion(req.Context(), post.ID, version)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"social/internal/notifications"
+	"social/internal/pubsub"
+	"social/internal/store"
+	"strconv"
+	"time"
+)
+
+// streamed events, the notification events are published by the notifications service
+const (
+	EventPostPublished  = "post.published"
+	EventPostReposted   = "post.reposted"
+	EventCommentCreated = "comment.created"
+	EventMessageCreated = "message.created"
+	EventMessageRead    = "message.read"
+)
+
+const streamHeartbeat = time.Second * 30
+
+// streamRef is the payload of the events about posts and comments, the records
+// are loaded for every subscriber so each one only gets what it can see
+type streamRef struct {
+	PostId    int64 `json:"post_id"`
+	CommentId int64 `json:"comment_id,omitempty"`
+	UserId    int64 `json:"user_id"`
+}
+
+// publishEvent hands an event to the pub/sub, failures are logged and never fail the request
+func (app *application) publishEvent(ctx context.Context, topic, eventType string, data any) {
+	if err := app.events.Publish(context.WithoutCancel(ctx), topic, eventType, data); err != nil {
+		log.Printf("error publishing %s event: %s", eventType, err.Error())
+	}
+}
+
+// streamHandler pushes server-sent events to the client: its notifications and messages, the posts and reposts
+// of the users it follows and the new comments of the posts given with ?post_id=
+func (app *application) streamHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	ctx := req.Context()
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	following, err := app.store.Users.GetFollowing(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	topics := []string{pubsub.UserTopic(user.ID)}
+	for _, followed := range following {
+		topics = append(topics, pubsub.AuthorTopic(followed.ID))
+	}
+
+	for _, value := range req.URL.Query()["post_id"] {
+		postId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+
+		post, err := app.store.Posts.GetPostById(ctx, postId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		allowed, err := app.canViewPost(ctx, viewer, post)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+		if !allowed {
+			app.notFoundError(w, req, store.ErrNotFound)
+			return
+		}
+
+		topics = append(topics, pubsub.PostTopic(post.ID))
+	}
+
+	rc := http.NewResponseController(w)
+
+	// the stream stays open past the write timeout of the server
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	sub := app.events.Subscribe(topics...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			data, err := app.streamEventData(ctx, viewer, event)
+			if err != nil {
+				log.Printf("error preparing %s event: %s", event.Type, err.Error())
+				continue
+			}
+			if data == nil {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamEventData returns what the viewer receives for an event, nil when it is not for the viewer
+func (app *application) streamEventData(ctx context.Context, viewer store.Viewer, event pubsub.Event) (json.RawMessage, error) {
+
+	// published on the topic of the user, only to the users allowed to get them
+	switch event.Type {
+	case notifications.EventNotification, EventMessageCreated, EventMessageRead:
+		return event.Data, nil
+	}
+
+	var ref streamRef
+	if err := json.Unmarshal(event.Data, &ref); err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case EventPostPublished, EventPostReposted:
+		post, err := app.store.Posts.GetPostById(ctx, ref.PostId)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		rel, err := app.store.Users.GetRelationship(ctx, viewer.ID, post.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		// the feed leaves out muted authors
+		if post.UserId != viewer.ID && (rel.Muting || !viewer.CanViewPost(post, rel)) {
+			return nil, nil
+		}
+
+		if event.Type == EventPostReposted {
+			reposter, err := app.store.Users.GetRelationship(ctx, viewer.ID, ref.UserId)
+			if err != nil {
+				return nil, err
+			}
+			if reposter.Muting {
+				return nil, nil
+			}
+		}
+
+		return json.Marshal(map[string]any{"post": post, "user_id": ref.UserId})
+
+	case EventCommentCreated:
+		comment, err := app.store.Comments.GetById(ctx, ref.CommentId)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		rel, err := app.store.Users.GetRelationship(ctx, viewer.ID, comment.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		if comment.UserId != viewer.ID && !viewer.CanViewComment(comment, rel) {
+			return nil, nil
+		}
+
+		return json.Marshal(comment)
+
+	default:
+		return nil, nil
+	}
+}
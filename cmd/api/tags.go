@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"social/internal/content"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (app *application) getTagPostsHandler(w http.ResponseWriter, req *http.Request) {
+
+	tag, err := content.NormalizeTag(chi.URLParam(req, "tag"))
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err = fq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	posts, err := app.store.Tags.GetPosts(req.Context(), viewer, tag, fq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, req *http.Request) {
+
+	limit := 10
+	if value := req.URL.Query().Get("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 1 || l > 50 {
+			app.badRequestResponse(w, req, fmt.Errorf("limit must be between 1 and 50"))
+			return
+		}
+		limit = l
+	}
+
+	tags, err := app.store.Tags.GetTrending(req.Context(), app.config.TrendingWindow, app.config.TrendingHalfLife, limit)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
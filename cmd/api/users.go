@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"social/internal/store"
@@ -16,11 +17,32 @@ type userKey string
 
 const userCtx userKey = "user"
 
+// the user from the url, kept apart from the authenticated user in userCtx
+const targetUserCtx userKey = "targetUser"
+
 func (app *application) getUserHandler(w http.ResponseWriter, req *http.Request) {
 
 	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
 
-	if err := app.JsonResponse(w, http.StatusOK, user); err != nil {
+	rel, err := app.store.Users.GetRelationship(req.Context(), user.ID, target.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	viewer, err := app.getViewer(req)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if !viewer.CanViewUser(target, rel) {
+		app.notFoundError(w, req, store.ErrNotFound)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, target); err != nil {
 		app.internalServerError(w, req, err)
 		return
 	}
@@ -29,21 +51,52 @@ func (app *application) getUserHandler(w http.ResponseWriter, req *http.Request)
 func (app *application) followUserHandler(w http.ResponseWriter, req *http.Request) {
 
 	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
 
-	var payload types.FollowUserPayload
-	if err := ParseJSON(w, req, &payload); err != nil {
-		app.badRequestResponse(w, req, err)
+	if !app.parseFollowPayload(w, req, user) {
 		return
 	}
 
-	if err := Validate.Struct(payload); err != nil {
-		app.badRequestResponse(w, req, err)
+	if user.ID == target.ID {
+		app.badRequestResponse(w, req, fmt.Errorf("you can not follow yourself"))
 		return
 	}
 
 	ctx := req.Context()
 
-	err := app.store.Users.Follow(ctx, user.ID, payload.UserID)
+	rel, err := app.store.Users.GetRelationship(ctx, user.ID, target.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	// blocked users are invisible to each other
+	if rel.Blocked() {
+		app.notFoundError(w, req, store.ErrNotFound)
+		return
+	}
+
+	// following a private account needs the approval of its owner
+	if target.IsPrivate && !rel.Following {
+		if err := app.store.Users.RequestFollow(ctx, target.ID, user.ID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrConflict):
+				app.conflictError(w, req, fmt.Errorf("follow request already sent"))
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		app.notifications.Publish(ctx, target.ID, user.ID, types.NotificationFollowRequest, "user", target.ID)
+
+		if err := app.JsonResponse(w, http.StatusAccepted, map[string]string{"message": "follow request sent"}); err != nil {
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	err = app.store.Users.Follow(ctx, target.ID, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrConflict):
@@ -57,6 +110,10 @@ func (app *application) followUserHandler(w http.ResponseWriter, req *http.Reque
 
 	}
 
+	app.timeline.Followed(ctx, target.ID, user.ID)
+	app.notifications.Publish(ctx, target.ID, user.ID, types.NotificationFollow, "user", target.ID)
+	app.webhooks.Publish(ctx, types.WebhookUserFollowed, []int64{target.ID, user.ID}, followedEvent(target.ID, user.ID))
+
 	if err := app.JsonResponse(w, http.StatusNoContent, map[string]string{"message": "success"}); err != nil {
 		app.internalServerError(w, req, err)
 		return
@@ -66,22 +123,21 @@ func (app *application) followUserHandler(w http.ResponseWriter, req *http.Reque
 func (app *application) unfollowUserHandler(w http.ResponseWriter, req *http.Request) {
 
 	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
 
-	var payload types.FollowUserPayload
-
-	if err := ParseJSON(w, req, &payload); err != nil {
-		app.internalServerError(w, req, err)
+	if !app.parseFollowPayload(w, req, user) {
 		return
 	}
 
-	if err := Validate.Struct(payload); err != nil {
-		app.badRequestResponse(w, req, err)
+	ctx := req.Context()
+
+	// a pending follow request is withdrawn as well
+	if err := app.store.Users.CancelFollowRequest(ctx, target.ID, user.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, req, err)
 		return
 	}
 
-	ctx := req.Context()
-
-	err := app.store.Users.Unfollow(ctx, user.ID, payload.UserID)
+	err := app.store.Users.Unfollow(ctx, target.ID, user.ID)
 
 	if err != nil {
 		switch {
@@ -95,12 +151,256 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, req *http.Req
 		}
 	}
 
+	app.timeline.Unfollowed(ctx, target.ID, user.ID)
+
 	if err := app.JsonResponse(w, http.StatusNoContent, map[string]string{"message": "success"}); err != nil {
 		app.internalServerError(w, req, err)
 		return
 	}
 }
 
+func (app *application) blockUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if user.ID == target.ID {
+		app.badRequestResponse(w, req, fmt.Errorf("you can not block yourself"))
+		return
+	}
+
+	if err := app.store.Users.Block(req.Context(), user.ID, target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	// blocking removes the follows in both directions
+	app.timeline.Unfollowed(req.Context(), target.ID, user.ID)
+	app.timeline.Unfollowed(req.Context(), user.ID, target.ID)
+
+	if err := app.JsonResponse(w, http.StatusNoContent, map[string]string{"message": "success"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) unblockUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if err := app.store.Users.Unblock(req.Context(), user.ID, target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusNoContent, map[string]string{"message": "success"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) muteUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if user.ID == target.ID {
+		app.badRequestResponse(w, req, fmt.Errorf("you can not mute yourself"))
+		return
+	}
+
+	if err := app.store.Users.Mute(req.Context(), user.ID, target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusNoContent, map[string]string{"message": "success"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) unmuteUserHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	target := getTargetUserFromCtx(req)
+
+	if err := app.store.Users.Unmute(req.Context(), user.ID, target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusNoContent, map[string]string{"message": "success"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getBlockedUsersHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	users, err := app.store.Users.GetBlocked(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getMutedUsersHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	users, err := app.store.Users.GetMuted(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) updateMeHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	var payload types.UpdateUserPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if payload.IsPrivate != nil {
+		approved, err := app.store.Users.SetPrivate(req.Context(), user.ID, *payload.IsPrivate)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+		user.IsPrivate = *payload.IsPrivate
+
+		// going public approves the pending follow requests
+		for _, requesterId := range approved {
+			app.followRequestApproved(req.Context(), user.ID, requesterId)
+		}
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	users, err := app.store.Users.GetFollowRequests(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	requesterId, err := strconv.ParseInt(chi.URLParam(req, "requesterId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := app.store.Users.ApproveFollowRequest(req.Context(), user.ID, requesterId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.followRequestApproved(req.Context(), user.ID, requesterId)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "follow request approved"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// followRequestApproved backfills the timeline of the new follower and tells the requester and the webhooks
+func (app *application) followRequestApproved(ctx context.Context, userId, requesterId int64) {
+	app.timeline.Followed(ctx, userId, requesterId)
+	app.notifications.Publish(ctx, requesterId, userId, types.NotificationFollowAccepted, "user", userId)
+	app.webhooks.Publish(ctx, types.WebhookUserFollowed, []int64{userId, requesterId}, followedEvent(userId, requesterId))
+}
+
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	requesterId, err := strconv.ParseInt(chi.URLParam(req, "requesterId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := app.store.Users.CancelFollowRequest(req.Context(), user.ID, requesterId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "follow request rejected"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
 func (app *application) activateUserHandler(w http.ResponseWriter, req *http.Request) {
 
 	token := chi.URLParam(req, "token")
@@ -132,7 +432,7 @@ func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 		userIdParam := chi.URLParam(req, "userId")
 		userId, err := strconv.ParseInt(userIdParam, 10, 64)
 		if err != nil {
-			app.internalServerError(w, req, err)
+			app.badRequestResponse(w, req, err)
 			return
 		}
 
@@ -147,14 +447,43 @@ func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 			default:
 				app.internalServerError(w, req, err)
 			}
+			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, targetUserCtx, user)
 		next.ServeHTTP(w, req.WithContext(ctx))
 
 	})
 }
 
+// parseFollowPayload reads the follower of a follow or unfollow request, it has to be the
+// authenticated user. the response is written when it returns false
+func (app *application) parseFollowPayload(w http.ResponseWriter, req *http.Request, user *types.User) bool {
+
+	var payload types.FollowUserPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return false
+	}
+
+	if payload.UserID != user.ID {
+		app.forbiddenResponse(w, req)
+		return false
+	}
+
+	return true
+}
+
+// followedEvent is the webhook payload of a follow
+func followedEvent(userId, followerId int64) map[string]int64 {
+	return map[string]int64{"user_id": userId, "follower_id": followerId}
+}
+
 func getUserFromCtx(req *http.Request) *types.User {
 	user, ok := req.Context().Value(userCtx).(*types.User)
 	if !ok {
@@ -163,3 +492,12 @@ func getUserFromCtx(req *http.Request) *types.User {
 	}
 	return user
 }
+
+func getTargetUserFromCtx(req *http.Request) *types.User {
+	user, ok := req.Context().Value(targetUserCtx).(*types.User)
+	if !ok {
+		log.Println("error: failed to get target user from context")
+		return nil
+	}
+	return user
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"social/internal/store"
+	"social/internal/types"
+	"social/internal/webhooks"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// global webhooks get the events of every user
+const PermManageWebhooks = "webhooks:manage"
+
+type webhookKey string
+
+const webhookCtx webhookKey = "webhook"
+
+func (app *application) getWebhooksHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	hooks, err := app.store.Webhooks.GetByUserId(req.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, hooks); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+
+	var payload types.CreateWebhookPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if payload.Global {
+		allowed, err := app.hasPermission(req.Context(), user, PermManageWebhooks)
+		if err != nil {
+			app.internalServerError(w, req, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, req)
+			return
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	webhook := &types.Webhook{
+		UserId: user.ID,
+		Global: payload.Global,
+		URL:    payload.URL,
+		Secret: secret,
+		Events: payload.Events,
+	}
+
+	if err := app.store.Webhooks.Create(req.Context(), webhook); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	app.audit(req, user.ID, AuditWebhookCreate, "webhook", webhook.ID, nil, webhook)
+
+	// the secret is only returned once, at creation time
+	if err := app.JsonResponse(w, http.StatusCreated, types.WebhookWithSecret{Webhook: webhook, Secret: secret}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getWebhookHandler(w http.ResponseWriter, req *http.Request) {
+
+	webhook := getWebhookFromCtx(req)
+
+	if err := app.JsonResponse(w, http.StatusOK, webhook); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) updateWebhookHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	webhook := getWebhookFromCtx(req)
+
+	var payload types.UpdateWebhookPayload
+	if err := ParseJSON(w, req, &payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	before := *webhook
+
+	if payload.URL != nil {
+		webhook.URL = *payload.URL
+	}
+	if payload.Events != nil {
+		webhook.Events = payload.Events
+	}
+	if payload.Active != nil {
+		webhook.Active = *payload.Active
+	}
+
+	if err := app.store.Webhooks.Update(req.Context(), webhook); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditWebhookUpdate, "webhook", webhook.ID, before, webhook)
+
+	if err := app.JsonResponse(w, http.StatusOK, webhook); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, req *http.Request) {
+
+	user := getUserFromCtx(req)
+	webhook := getWebhookFromCtx(req)
+
+	if err := app.store.Webhooks.Delete(req.Context(), webhook.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	app.audit(req, user.ID, AuditWebhookDelete, "webhook", webhook.ID, webhook, nil)
+
+	if err := app.JsonResponse(w, http.StatusOK, map[string]string{"message": "webhook deleted"}); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, req *http.Request) {
+
+	webhook := getWebhookFromCtx(req)
+
+	dq := store.DeliveryQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	dq, err := dq.Parse(req)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if err := Validate.Struct(dq); err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	deliveries, err := app.store.Webhooks.GetDeliveries(req.Context(), webhook.ID, dq)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusOK, deliveries); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// replayWebhookDeliveryHandler sends the event of a delivery again and returns the new delivery
+func (app *application) replayWebhookDeliveryHandler(w http.ResponseWriter, req *http.Request) {
+
+	webhook := getWebhookFromCtx(req)
+
+	deliveryId, err := strconv.ParseInt(chi.URLParam(req, "deliveryId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, req, err)
+		return
+	}
+
+	if !webhook.Active {
+		app.conflictError(w, req, fmt.Errorf("webhook is disabled"))
+		return
+	}
+
+	ctx := req.Context()
+
+	delivery, err := app.store.Webhooks.GetDelivery(ctx, webhook.ID, deliveryId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, req, err)
+		default:
+			app.internalServerError(w, req, err)
+		}
+		return
+	}
+
+	replay, err := app.webhooks.Replay(ctx, delivery)
+	if err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+
+	if err := app.JsonResponse(w, http.StatusCreated, replay); err != nil {
+		app.internalServerError(w, req, err)
+		return
+	}
+}
+
+// webhookContextMiddleware loads the webhook from the url, it is only visible to its owner
+// and, for global webhooks, to the users allowed to manage them
+func (app *application) webhookContextMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		webhookId, err := strconv.ParseInt(chi.URLParam(req, "webhookId"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, req, err)
+			return
+		}
+
+		user := getUserFromCtx(req)
+		ctx := req.Context()
+
+		webhook, err := app.store.Webhooks.GetById(ctx, webhookId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, req, err)
+			default:
+				app.internalServerError(w, req, err)
+			}
+			return
+		}
+
+		allowed := webhook.UserId == user.ID
+		if !allowed && webhook.Global {
+			allowed, err = app.hasPermission(ctx, user, PermManageWebhooks)
+			if err != nil {
+				app.internalServerError(w, req, err)
+				return
+			}
+		}
+
+		if !allowed {
+			app.notFoundError(w, req, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, webhookCtx, webhook)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func getWebhookFromCtx(req *http.Request) *types.Webhook {
+	webhook, ok := req.Context().Value(webhookCtx).(*types.Webhook)
+	if !ok {
+		log.Println("error: failed to retrieve webhook from context")
+		return nil
+	}
+	return webhook
+}
+
+// runWebhookRetries attempts the webhook deliveries that are due, on every tick
+// and whenever the dispatcher queues new ones
+func (app *application) runWebhookRetries(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means more are waiting
+		for {
+			attempted, err := app.webhooks.RetryDue(ctx, 100)
+			if err != nil {
+				log.Println("error retrying webhook deliveries: ", err.Error())
+			}
+			if err != nil || attempted < 100 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-app.webhooks.Wake():
+		}
+	}
+}
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    name varchar(100) NOT NULL,
+    prefix varchar(16) UNIQUE NOT NULL,
+    key_hash varchar(64) NOT NULL,
+    scopes varchar(50)[] NOT NULL DEFAULT '{}',
+    expires_at timestamp(0) with time zone,
+    last_used_at timestamp(0) with time zone,
+    revoked_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
\ No newline at end of file
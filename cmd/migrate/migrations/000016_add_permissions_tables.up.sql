@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS permissions(
+    id bigserial PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+    role_id bigint NOT NULL,
+    permission_id bigint NOT NULL,
+
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('posts:update:any', 'Update posts of other users'),
+    ('posts:delete:any', 'Delete posts of other users'),
+    ('comments:moderate', 'Update and delete comments of other users'),
+    ('roles:manage', 'Manage the permissions granted to roles');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'moderator' AND p.name IN ('posts:update:any', 'comments:moderate');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin';
\ No newline at end of file
@@ -0,0 +1,16 @@
+ALTER TABLE
+    user_invitations DROP CONSTRAINT IF EXISTS fk_invitations_user;
+
+ALTER TABLE
+    followers DROP CONSTRAINT IF EXISTS fk_followers_follower;
+
+ALTER TABLE
+    comments DROP CONSTRAINT IF EXISTS fk_comments_user;
+
+ALTER TABLE
+    posts DROP CONSTRAINT IF EXISTS fk_user;
+
+ALTER TABLE
+    posts
+ADD
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id);
\ No newline at end of file
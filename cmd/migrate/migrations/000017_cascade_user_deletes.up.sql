@@ -0,0 +1,28 @@
+ALTER TABLE
+    posts DROP CONSTRAINT IF EXISTS fk_user;
+
+ALTER TABLE
+    posts
+ADD
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+DELETE FROM comments WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE
+    comments
+ADD
+    CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+DELETE FROM followers WHERE follower_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE
+    followers
+ADD
+    CONSTRAINT fk_followers_follower FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE;
+
+DELETE FROM user_invitations WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE
+    user_invitations
+ADD
+    CONSTRAINT fk_invitations_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name IN ('users:manage', 'users:assign-role', 'users:delete');
+
+ALTER TABLE
+    users DROP COLUMN tokens_revoked_at;
\ No newline at end of file
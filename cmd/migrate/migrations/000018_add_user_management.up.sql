@@ -0,0 +1,14 @@
+ALTER TABLE
+    users
+ADD
+    COLUMN tokens_revoked_at timestamp with time zone;
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('users:manage', 'List, inspect, deactivate and log out any user'),
+    ('users:assign-role', 'Change the role of a user'),
+    ('users:delete', 'Permanently delete a user and all of their content');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name IN ('users:manage', 'users:assign-role', 'users:delete');
\ No newline at end of file
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS audit_logs(
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    action varchar(100) NOT NULL,
+    target_type varchar(50) NOT NULL DEFAULT '',
+    target_id bigint,
+    before jsonb,
+    after jsonb,
+    request_id varchar(100) NOT NULL DEFAULT '',
+    ip varchar(64) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs (target_type, target_id);
+
+-- audit entries are append only, rows can only be removed by the retention job
+CREATE RULE audit_logs_no_update AS ON UPDATE TO audit_logs DO INSTEAD NOTHING;
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('audit:read', 'Read the audit log');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'audit:read';
\ No newline at end of file
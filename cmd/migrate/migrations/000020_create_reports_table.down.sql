@@ -0,0 +1,13 @@
+DELETE FROM permissions WHERE name = 'reports:moderate';
+
+ALTER TABLE
+    users DROP COLUMN suspended_until,
+    DROP COLUMN warning_count;
+
+ALTER TABLE
+    comments DROP COLUMN hidden_at;
+
+ALTER TABLE
+    posts DROP COLUMN hidden_at;
+
+DROP TABLE IF EXISTS reports;
\ No newline at end of file
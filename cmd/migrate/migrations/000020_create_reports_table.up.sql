@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS reports(
+    id bigserial PRIMARY KEY,
+    reporter_id bigint NOT NULL,
+    target_type varchar(20) NOT NULL,
+    target_id bigint NOT NULL,
+    reason varchar(30) NOT NULL,
+    details text NOT NULL DEFAULT '',
+    status varchar(20) NOT NULL DEFAULT 'open',
+    resolution varchar(20) NOT NULL DEFAULT '',
+    note text NOT NULL DEFAULT '',
+    resolved_by bigint,
+    resolved_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (reporter_id, target_type, target_id),
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports (target_type, target_id);
+
+ALTER TABLE
+    posts
+ADD
+    COLUMN hidden_at timestamp(0) with time zone;
+
+ALTER TABLE
+    comments
+ADD
+    COLUMN hidden_at timestamp(0) with time zone;
+
+ALTER TABLE
+    users
+ADD
+    COLUMN warning_count int NOT NULL DEFAULT 0,
+ADD
+    COLUMN suspended_until timestamp(0) with time zone;
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('reports:moderate', 'Triage reported content and see hidden content');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name IN ('moderator', 'admin') AND p.name = 'reports:moderate';
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_mutes;
+
+DROP TABLE IF EXISTS user_blocks;
\ No newline at end of file
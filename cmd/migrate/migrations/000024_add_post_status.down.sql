@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_scheduled;
+
+ALTER TABLE
+    posts DROP COLUMN publish_at,
+    DROP COLUMN status;
\ No newline at end of file
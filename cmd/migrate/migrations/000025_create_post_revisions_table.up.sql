@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS post_revisions(
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL,
+    version int NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    visibility varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (post_id, version),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+ALTER TABLE
+    posts
+ADD
+    COLUMN edited_at timestamp(0) with time zone;
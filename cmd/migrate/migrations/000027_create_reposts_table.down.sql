@@ -0,0 +1,4 @@
+ALTER TABLE
+    posts DROP COLUMN IF EXISTS quote_post_id;
+
+DROP TABLE IF EXISTS reposts;
@@ -0,0 +1,15 @@
+UPDATE
+    posts
+SET
+    tags = ARRAY(
+        SELECT
+            DISTINCT lower(trim(LEADING '#' FROM t))
+        FROM
+            unnest(tags) AS t
+    )
+WHERE
+    tags IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_tags ON posts USING gin (tags);
+
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
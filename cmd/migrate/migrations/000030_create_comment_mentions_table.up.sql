@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS comment_mentions(
+    comment_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user_id ON comment_mentions (user_id);
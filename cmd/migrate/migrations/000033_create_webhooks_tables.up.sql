@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS webhooks(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    -- global webhooks get the events of every user, only admins register them
+    global boolean NOT NULL DEFAULT false,
+    url text NOT NULL,
+    secret varchar(100) NOT NULL,
+    events text[] NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    -- consecutive failed attempts, the webhook is disabled past a threshold
+    failure_count int NOT NULL DEFAULT 0,
+    disabled_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+    id bigserial PRIMARY KEY,
+    webhook_id bigint NOT NULL,
+    event varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone,
+    response_status int,
+    response_body text NOT NULL DEFAULT '',
+    error text NOT NULL DEFAULT '',
+    duration_ms int,
+    replay_of bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    delivered_at timestamp(0) with time zone,
+
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE,
+    FOREIGN KEY (replay_of) REFERENCES webhook_deliveries(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('webhooks:manage', 'Register webhooks receiving the events of every user');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'webhooks:manage';
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS timeline_items;
+
+DROP TRIGGER IF EXISTS followers_count_update ON followers;
+
+DROP FUNCTION IF EXISTS update_followers_count;
+
+ALTER TABLE users DROP COLUMN IF EXISTS followers_count;
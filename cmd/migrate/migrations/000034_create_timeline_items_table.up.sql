@@ -0,0 +1,47 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count int NOT NULL DEFAULT 0;
+
+UPDATE users u SET followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id);
+
+CREATE OR REPLACE FUNCTION update_followers_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+    ELSE
+        UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER followers_count_update AFTER INSERT OR DELETE ON followers
+FOR EACH ROW EXECUTE FUNCTION update_followers_count();
+
+-- the home timeline of every user, source_id is the followed user the post came through,
+-- its author or, when reposted is set, the user who reposted it
+CREATE TABLE IF NOT EXISTS timeline_items(
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    source_id bigint NOT NULL,
+    reposted boolean NOT NULL DEFAULT false,
+    activity_at timestamp(0) with time zone NOT NULL,
+
+    PRIMARY KEY (user_id, post_id, source_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_items_user_id_activity_at ON timeline_items (user_id, activity_at DESC);
+
+INSERT INTO timeline_items (user_id,post_id,source_id,activity_at)
+SELECT f.follower_id, p.id, p.user_id, p.created_at
+FROM posts p
+JOIN followers f ON f.user_id = p.user_id
+WHERE p.status = 'published' AND p.deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+INSERT INTO timeline_items (user_id,post_id,source_id,reposted,activity_at)
+SELECT f.follower_id, r.post_id, r.user_id, true, r.created_at
+FROM reposts r
+JOIN followers f ON f.user_id = r.user_id
+ON CONFLICT DO NOTHING;
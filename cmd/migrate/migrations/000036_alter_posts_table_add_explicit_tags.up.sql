@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS explicit_tags varchar(100)[] NOT NULL DEFAULT '{}';
+
+-- the tags given and the hashtags of the content were not told apart so far, existing posts keep all their tags
+UPDATE posts SET explicit_tags = COALESCE(tags, '{}');
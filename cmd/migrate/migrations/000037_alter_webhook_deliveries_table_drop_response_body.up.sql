@@ -0,0 +1,2 @@
+-- only the status of the responses is kept, their bodies may echo content of internal services
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS response_body;
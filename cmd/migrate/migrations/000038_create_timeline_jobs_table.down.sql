@@ -0,0 +1,4 @@
+ALTER TABLE reposts DROP COLUMN IF EXISTS read_merged;
+ALTER TABLE posts DROP COLUMN IF EXISTS read_merged;
+
+DROP TABLE IF EXISTS timeline_jobs;
@@ -0,0 +1,27 @@
+-- the timeline writes waiting for the worker, drained in id order
+CREATE TABLE IF NOT EXISTS timeline_jobs(
+    id bigserial PRIMARY KEY,
+    kind varchar(20) NOT NULL,
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL DEFAULT 0,
+    follower_id bigint NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- posts and reposts merged into the timelines at read time instead of being written into them,
+-- decided once when they are published so they do not depend on the current follower count
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS read_merged boolean NOT NULL DEFAULT false;
+ALTER TABLE reposts ADD COLUMN IF NOT EXISTS read_merged boolean NOT NULL DEFAULT false;
+
+-- the posts and reposts left out of the timelines so far, by the users over the default threshold
+UPDATE posts p SET read_merged = true
+FROM users u
+WHERE u.id = p.user_id AND u.followers_count >= 10000 AND NOT EXISTS (
+    SELECT 1 FROM timeline_items t WHERE t.post_id = p.id AND t.source_id = p.user_id AND NOT t.reposted
+);
+
+UPDATE reposts r SET read_merged = true
+FROM users u
+WHERE u.id = r.user_id AND u.followers_count >= 10000 AND NOT EXISTS (
+    SELECT 1 FROM timeline_items t WHERE t.post_id = r.post_id AND t.source_id = r.user_id AND t.reposted
+);
@@ -0,0 +1,5 @@
+ALTER TABLE timeline_jobs
+    DROP COLUMN IF EXISTS error,
+    DROP COLUMN IF EXISTS failed_at,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS attempts;
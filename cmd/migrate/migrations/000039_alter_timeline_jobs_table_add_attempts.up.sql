@@ -0,0 +1,6 @@
+-- failed jobs are retried after next_attempt_at and kept with failed_at once they ran out of attempts
+ALTER TABLE timeline_jobs
+    ADD COLUMN IF NOT EXISTS attempts int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS failed_at timestamp(0) with time zone,
+    ADD COLUMN IF NOT EXISTS error text NOT NULL DEFAULT '';
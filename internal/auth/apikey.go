@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// api keys look like: sk_<prefix>_<secret>
+// the prefix is stored in plain text so a key can be identified,
+// the full key is only stored as a sha256 hash
+const (
+	APIKeyIdentifier = "sk"
+	apiKeyPrefixLen  = 8
+	apiKeySecretLen  = 32
+)
+
+func GenerateAPIKey() (key string, prefix string, hash string, err error) {
+
+	prefixBytes := make([]byte, apiKeyPrefixLen/2)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+
+	secretBytes := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = fmt.Sprintf("%s_%s_%s", APIKeyIdentifier, prefix, hex.EncodeToString(secretBytes))
+
+	return key, prefix, HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// IsAPIKey reports whether the token has the shape of an api key rather than a jwt
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyIdentifier+"_")
+}
+
+func ParseAPIKeyPrefix(key string) (string, error) {
+
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != APIKeyIdentifier || len(parts[1]) != apiKeyPrefixLen {
+		return "", fmt.Errorf("api key is malformed")
+	}
+
+	return parts[1], nil
+}
+
+func CompareAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrMiss = errors.New("cache miss")
+
+// loads running longer are canceled, Invalidate deletes the keys again once they are over
+const loadTimeout = time.Second * 10
+
+// Cache stores encoded values under a key until their ttl is over
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Loader reads values through a cache. concurrent misses of a key share a single load
+// and the ttl gets up to a tenth of jitter so values loaded together do not expire together
+type Loader struct {
+	cache  Cache
+	ttl    time.Duration
+	flight singleflight.Group
+
+	// generations of the keys being loaded, bumped by Invalidate so a load
+	// that read the value before a write does not cache it after the write
+	mu          sync.Mutex
+	loading     map[string]int
+	generations map[string]uint64
+}
+
+func NewLoader(cache Cache, ttl time.Duration) *Loader {
+	return &Loader{
+		cache:       cache,
+		ttl:         ttl,
+		loading:     map[string]int{},
+		generations: map[string]uint64{},
+	}
+}
+
+// Load returns the cached value of the key or the one of load, errors of load are not cached.
+// the cache is only an optimisation, when it fails the value is loaded as if it was missing.
+// every caller gets its own copy of the value
+func Load[T any](ctx context.Context, loader *Loader, key string, load func(context.Context) (T, error)) (T, error) {
+
+	var value T
+
+	data, err := loader.cache.Get(ctx, key)
+	switch {
+	case err == nil:
+		if err := decode(data, &value); err == nil {
+			return value, nil
+		}
+		log.Printf("cache: failed to decode %s: %v", key, err)
+	case !errors.Is(err, ErrMiss):
+		log.Printf("cache: failed to get %s: %v", key, err)
+	}
+
+	// the load is shared, the request that started it must not cancel it for the others
+	shared, err, _ := loader.flight.Do(key, func() (any, error) {
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		generation := loader.begin(key)
+		defer loader.end(key)
+
+		loaded, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := encode(loaded)
+		if err != nil {
+			return nil, err
+		}
+
+		if loader.generation(key) != generation {
+			return data, nil
+		}
+
+		if err := loader.cache.Set(ctx, key, data, loader.expiry()); err != nil {
+			log.Printf("cache: failed to set %s: %v", key, err)
+		}
+
+		// invalidated while it was being set
+		if loader.generation(key) != generation {
+			loader.delete(ctx, key)
+		}
+
+		return data, nil
+	})
+	if err != nil {
+		return value, err
+	}
+
+	err = decode(shared.([]byte), &value)
+	return value, err
+}
+
+// Invalidate removes keys after a write, loads already running for them are not joined anymore
+// and do not cache what they read. loads of other instances sharing the cache can not be told,
+// the keys are deleted again once the loads running at the time of the write are over
+func (loader *Loader) Invalidate(ctx context.Context, keys ...string) {
+
+	loader.mu.Lock()
+	for _, key := range keys {
+		if loader.loading[key] > 0 {
+			loader.generations[key]++
+		}
+		loader.flight.Forget(key)
+	}
+	loader.mu.Unlock()
+
+	loader.delete(ctx, keys...)
+
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(loadTimeout, func() {
+		loader.delete(ctx, keys...)
+	})
+}
+
+func (loader *Loader) delete(ctx context.Context, keys ...string) {
+	if err := loader.cache.Delete(ctx, keys...); err != nil {
+		log.Printf("cache: failed to delete %v: %v", keys, err)
+	}
+}
+
+// begin registers a load of the key and returns the generation it started at
+func (loader *Loader) begin(key string) uint64 {
+
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+
+	loader.loading[key]++
+
+	return loader.generations[key]
+}
+
+func (loader *Loader) end(key string) {
+
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+
+	loader.loading[key]--
+	if loader.loading[key] == 0 {
+		delete(loader.loading, key)
+		delete(loader.generations, key)
+	}
+}
+
+func (loader *Loader) generation(key string) uint64 {
+
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+
+	return loader.generations[key]
+}
+
+func (loader *Loader) expiry() time.Duration {
+
+	jitter := int64(loader.ttl / 10)
+	if jitter <= 0 {
+		return loader.ttl
+	}
+
+	return loader.ttl + time.Duration(rand.Int64N(jitter))
+}
+
+func encode(value any) ([]byte, error) {
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decode(data []byte, value any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis uses the server of REDIS_ADDR when it is set, an in-process miniredis otherwise
+func newTestRedis(t *testing.T) *Redis {
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(t).Addr()
+	}
+
+	redis, err := NewRedis(addr, "", 0, "test:"+t.Name()+":")
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %s", err)
+	}
+	t.Cleanup(func() { redis.Close() })
+
+	return redis
+}
+
+func TestCaches(t *testing.T) {
+
+	caches := map[string]func(t *testing.T) Cache{
+		"lru":   func(t *testing.T) Cache { return NewLRU(10) },
+		"redis": func(t *testing.T) Cache { return newTestRedis(t) },
+	}
+
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+
+			ctx := context.Background()
+			c := newCache(t)
+
+			if _, err := c.Get(ctx, "key"); !errors.Is(err, ErrMiss) {
+				t.Fatalf("expected a miss, got %v", err)
+			}
+
+			if err := c.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			value, err := c.Get(ctx, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != "value" {
+				t.Errorf("expected %q, got %q", "value", value)
+			}
+
+			if err := c.Delete(ctx, "key"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Get(ctx, "key"); !errors.Is(err, ErrMiss) {
+				t.Errorf("expected a miss after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	c.Set(ctx, "a", []byte("a"), time.Minute)
+	c.Set(ctx, "b", []byte("b"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("c"), time.Minute)
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected b to be evicted, got %v", err)
+	}
+	if _, err := c.Get(ctx, "a"); err != nil {
+		t.Errorf("expected a to be kept, got %v", err)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", c.Len())
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "key", []byte("value"), time.Minute)
+
+	now = now.Add(time.Minute)
+
+	if _, err := c.Get(ctx, "key"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected the key to expire, got %v", err)
+	}
+}
+
+type testUser struct {
+	ID   int64
+	Name string
+}
+
+func TestLoadSharesConcurrentMisses(t *testing.T) {
+
+	ctx := context.Background()
+	loader := NewLoader(NewLRU(10), time.Minute)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+
+	load := func(ctx context.Context) (*testUser, error) {
+		loads.Add(1)
+		<-release
+		return &testUser{ID: 1, Name: "gopher"}, nil
+	}
+
+	var wg sync.WaitGroup
+	users := make([]*testUser, 10)
+
+	for i := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := Load(ctx, loader, "user:1", load)
+			if err != nil {
+				t.Error(err)
+			}
+			users[i] = user
+		}()
+	}
+
+	// let the callers pile up on the running load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("expected a single load, got %d", got)
+	}
+
+	for i, user := range users {
+		if user == nil || user.Name != "gopher" {
+			t.Fatalf("caller %d got %+v", i, user)
+		}
+		if i > 0 && user == users[0] {
+			t.Errorf("callers share the same value")
+		}
+	}
+
+	if _, err := Load(ctx, loader, "user:1", load); err != nil {
+		t.Fatal(err)
+	}
+	if got := loads.Load(); got != 1 {
+		t.Errorf("expected the cached value to be used, got %d loads", got)
+	}
+}
+
+func TestLoadAfterInvalidate(t *testing.T) {
+
+	ctx := context.Background()
+	loader := NewLoader(newTestRedis(t), time.Minute)
+
+	name := "gopher"
+	load := func(ctx context.Context) (*testUser, error) {
+		return &testUser{ID: 1, Name: name}, nil
+	}
+
+	if _, err := Load(ctx, loader, "user:1", load); err != nil {
+		t.Fatal(err)
+	}
+
+	name = "renamed"
+
+	user, err := Load(ctx, loader, "user:1", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "gopher" {
+		t.Errorf("expected the cached name, got %q", user.Name)
+	}
+
+	loader.Invalidate(ctx, "user:1")
+
+	user, err = Load(ctx, loader, "user:1", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "renamed" {
+		t.Errorf("expected the reloaded name, got %q", user.Name)
+	}
+}
+
+func TestLoadInvalidatedWhileLoading(t *testing.T) {
+
+	ctx := context.Background()
+	lru := NewLRU(10)
+	loader := NewLoader(lru, time.Minute)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	// the load reads the value before the write and returns after it
+	load := func(ctx context.Context) (*testUser, error) {
+		close(started)
+		<-release
+		return &testUser{ID: 1, Name: "stale"}, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := Load(ctx, loader, "user:1", load); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-started
+	loader.Invalidate(ctx, "user:1")
+	close(release)
+	<-done
+
+	if _, err := lru.Get(ctx, "user:1"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expected the stale value not to be cached, got %v", err)
+	}
+}
+
+func TestLoadDoesNotCacheErrors(t *testing.T) {
+
+	ctx := context.Background()
+	loader := NewLoader(NewLRU(10), time.Minute)
+
+	failure := errors.New("not found")
+	var loads int
+
+	load := func(ctx context.Context) (*testUser, error) {
+		loads++
+		return nil, failure
+	}
+
+	for range 2 {
+		if _, err := Load(ctx, loader, "user:1", load); !errors.Is(err, failure) {
+			t.Errorf("expected the load error, got %v", err)
+		}
+	}
+
+	if loads != 2 {
+		t.Errorf("expected every call to load, got %d loads", loads)
+	}
+}
@@ -0,0 +1,91 @@
+package content
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const MaxTagLength = 50
+
+var (
+	// the whole word is captured so an overlong hashtag is rejected instead of cut
+	hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#&])#([\p{L}\p{N}_]+)`)
+	tagRegex     = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+)
+
+// NormalizeTag lowercases a tag and drops a leading #, tags are made of letters, digits and underscores
+func NormalizeTag(tag string) (string, error) {
+
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength || !tagRegex.MatchString(tag) {
+		return "", fmt.Errorf("invalid tag %q, tags are up to %d letters, digits or underscores", tag, MaxTagLength)
+	}
+
+	return tag, nil
+}
+
+// ExtractHashtags returns the normalized #hashtags of a text, without duplicates
+func ExtractHashtags(text string) []string {
+
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range hashtagRegex.FindAllStringSubmatch(text, -1) {
+		tag, err := NormalizeTag(match[1])
+		if err != nil || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// NormalizeTags normalizes the tags given explicitly to a post, without duplicates
+func NormalizeTags(values []string) ([]string, error) {
+
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, value := range values {
+		tag, err := NormalizeTag(value)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// MergeTags normalizes the explicit tags of a post and adds the hashtags of its content
+func MergeTags(explicit []string, text string) ([]string, error) {
+
+	tags, err := NormalizeTags(explicit)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		seen[tag] = true
+	}
+
+	for _, tag := range ExtractHashtags(text) {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,51 @@
+package content
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@(\w{2,50})`)
+
+// ExtractMentions returns the usernames mentioned with @username, without duplicates
+func ExtractMentions(text string) []string {
+
+	usernames := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		username := match[1]
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
+
+// MentionSpan is a mention in a text, Start and End are character offsets with the @ included
+type MentionSpan struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// FindMentions returns every @username of a text with its position
+func FindMentions(text string) []MentionSpan {
+
+	spans := []MentionSpan{}
+
+	for _, match := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		// match[2] and match[3] delimit the username, the @ sits right before it
+		start := utf8.RuneCountInString(text[:match[2]-1])
+		spans = append(spans, MentionSpan{
+			Username: text[match[2]:match[3]],
+			Start:    start,
+			End:      start + 1 + utf8.RuneCountInString(text[match[2]:match[3]]),
+		})
+	}
+
+	return spans
+}
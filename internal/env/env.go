@@ -1,6 +1,7 @@
 package env
 
 import (
+	"log"
 	"os"
 	"strconv"
 	"time"
@@ -15,6 +16,65 @@ type Config struct {
 	Env         string
 	FrontendURL string
 	JWTConfig   JWTConfig
+
+	PermissionsCacheTTL time.Duration
+	AuditLogRetention   time.Duration
+
+	// deleted posts and comments can be restored during the grace period and are purged after the retention
+	DeleteGracePeriod time.Duration
+	DeleteRetention   time.Duration
+
+	// trending tags are computed over the window, the weight of a post halves every half life
+	TrendingWindow   time.Duration
+	TrendingHalfLife time.Duration
+
+	// "local" keeps real-time events within the instance, "postgres" shares them through LISTEN/NOTIFY
+	PubSubDriver string
+
+	// failed webhook deliveries are retried up to WebhookMaxAttempts, a webhook is disabled
+	// after WebhookDisableAfter consecutive failed attempts
+	WebhookMaxAttempts  int
+	WebhookDisableAfter int
+
+	// posts are pushed to the timelines of the followers of their author below FanoutThreshold followers,
+	// merged at read time when published above it. a new follower gets the last TimelineBackfill posts of the followed user
+	FanoutThreshold  int
+	TimelineBackfill int
+
+	Ranking RankingConfig
+
+	// how many posts a user can pin to the top of their profile
+	MaxPinnedPosts int
+
+	Cache CacheConfig
+}
+
+// CacheConfig picks where users and posts are cached, "none" turns the cache off, "redis" shares
+// it between instances and "memory" keeps up to Size entries in the process. a write only clears
+// the memory cache of the instance handling it, so "memory" is for single-instance deployments only
+type CacheConfig struct {
+	Driver string
+	TTL    time.Duration
+	Size   int
+	Redis  RedisConfig
+}
+
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+// RankingConfig weights the signals of the ranked feed, see store.Ranking
+type RankingConfig struct {
+	RecencyWeight     float64
+	EngagementWeight  float64
+	AffinityWeight    float64
+	TagInterestWeight float64
+
+	HalfLife time.Duration
+	Window   time.Duration
+	History  time.Duration
 }
 
 type dbConfig struct {
@@ -69,6 +129,37 @@ func initConfig() Config {
 			Exp:    time.Hour * 24 * 3,
 			Issuer: "gophersocial",
 		},
+		PermissionsCacheTTL: time.Minute * 5,
+		AuditLogRetention:   time.Hour * 24 * time.Duration(GetInt("AUDIT_LOG_RETENTION_DAYS", 365)),
+		DeleteGracePeriod:   time.Hour * 24 * time.Duration(GetInt("DELETE_GRACE_DAYS", 7)),
+		DeleteRetention:     time.Hour * 24 * time.Duration(GetInt("DELETE_RETENTION_DAYS", 30)),
+		TrendingWindow:      time.Hour * time.Duration(GetInt("TRENDING_WINDOW_HOURS", 24)),
+		TrendingHalfLife:    time.Hour * time.Duration(GetMinInt("TRENDING_HALF_LIFE_HOURS", 6, 1)),
+		PubSubDriver:        GetString("PUBSUB_DRIVER", "local"),
+		WebhookMaxAttempts:  GetInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookDisableAfter: GetInt("WEBHOOK_DISABLE_AFTER", 20),
+		FanoutThreshold:     GetInt("FANOUT_FOLLOWER_THRESHOLD", 10000),
+		TimelineBackfill:    GetInt("TIMELINE_BACKFILL_POSTS", 100),
+		MaxPinnedPosts:      GetInt("MAX_PINNED_POSTS", 3),
+		Cache: CacheConfig{
+			Driver: GetString("CACHE_DRIVER", "none"),
+			TTL:    time.Second * time.Duration(GetInt("CACHE_TTL_SECONDS", 60)),
+			Size:   GetInt("CACHE_SIZE", 10000),
+			Redis: RedisConfig{
+				Addr:     GetString("REDIS_ADDR", "localhost:6379"),
+				Password: GetString("REDIS_PASSWORD", ""),
+				DB:       GetInt("REDIS_DB", 0),
+			},
+		},
+		Ranking: RankingConfig{
+			RecencyWeight:     GetFloat("RANKING_RECENCY_WEIGHT", 3),
+			EngagementWeight:  GetFloat("RANKING_ENGAGEMENT_WEIGHT", 1),
+			AffinityWeight:    GetFloat("RANKING_AFFINITY_WEIGHT", 1.5),
+			TagInterestWeight: GetFloat("RANKING_TAG_INTEREST_WEIGHT", 1),
+			HalfLife:          time.Hour * time.Duration(GetMinInt("RANKING_HALF_LIFE_HOURS", 12, 1)),
+			Window:            time.Hour * time.Duration(GetInt("RANKING_WINDOW_HOURS", 72)),
+			History:           time.Hour * 24 * time.Duration(GetInt("RANKING_HISTORY_DAYS", 30)),
+		},
 	}
 }
 
@@ -82,6 +173,20 @@ func GetString(key, fallback string) string {
 	return value
 }
 
+func GetFloat(key string, fallback float64) float64 {
+
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	valFloat, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fallback
+	}
+	return valFloat
+}
+
 func GetInt(key string, fallback int) int {
 
 	value, ok := os.LookupEnv(key)
@@ -95,3 +200,15 @@ func GetInt(key string, fallback int) int {
 	}
 	return valInt
 }
+
+// GetMinInt is GetInt for settings that can not go below min, e.g. the divisors of a formula
+func GetMinInt(key string, fallback int, min int) int {
+
+	value := GetInt(key, fallback)
+	if value < min {
+		log.Printf("%s must be at least %d, using %d", key, min, fallback)
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"social/internal/pubsub"
+	"social/internal/store"
+	"social/internal/types"
+)
+
+const EventNotification = "notification"
+
+// Service is where handlers publish what happened to a user,
+// a notification is only stored when its recipient wants it
+type Service struct {
+	store  store.INotificationStore
+	events pubsub.PubSub
+}
+
+func NewService(store store.INotificationStore, events pubsub.PubSub) *Service {
+	return &Service{store: store, events: events}
+}
+
+// Publish notifies userId that actorId did something to a target. users are never
+// notified of their own actions and failures are logged without failing the caller
+func (s *Service) Publish(ctx context.Context, userId, actorId int64, notificationType, targetType string, targetId int64) {
+
+	if userId == actorId {
+		return
+	}
+
+	notification := &types.Notification{
+		UserId:     userId,
+		ActorId:    actorId,
+		Type:       notificationType,
+		TargetType: targetType,
+		TargetId:   targetId,
+	}
+
+	// the request context may be canceled as soon as the response is written
+	ctx = context.WithoutCancel(ctx)
+
+	if err := s.store.Create(ctx, notification); err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("error publishing %s notification: %s", notificationType, err.Error())
+		}
+		return
+	}
+
+	// connected clients of the recipient get it right away
+	if err := s.events.Publish(ctx, pubsub.UserTopic(userId), EventNotification, notification); err != nil {
+		log.Printf("error streaming %s notification: %s", notificationType, err.Error())
+	}
+}
+
+// Message describes a group of notifications, e.g. "gopher and 5 others reposted your post"
+func Message(group types.NotificationGroup) string {
+
+	actors := "someone"
+	switch {
+	case len(group.Actors) == 0:
+	case group.ActorsCount == 1:
+		actors = group.Actors[0].Username
+	case group.ActorsCount == 2 && len(group.Actors) > 1:
+		actors = fmt.Sprintf("%s and %s", group.Actors[0].Username, group.Actors[1].Username)
+	default:
+		actors = fmt.Sprintf("%s and %d others", group.Actors[0].Username, group.ActorsCount-1)
+	}
+
+	switch group.Type {
+	case types.NotificationFollow:
+		return actors + " followed you"
+	case types.NotificationFollowRequest:
+		return actors + " requested to follow you"
+	case types.NotificationFollowAccepted:
+		return actors + " accepted your follow request"
+	case types.NotificationMention:
+		return fmt.Sprintf("%s mentioned you in a %s", actors, group.TargetType)
+	case types.NotificationComment:
+		return actors + " commented on your post"
+	case types.NotificationRepost:
+		return actors + " reposted your post"
+	case types.NotificationQuote:
+		return actors + " quoted your post"
+	default:
+		return actors + " interacted with you"
+	}
+}
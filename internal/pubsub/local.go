@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+const subscriptionBuffer = 64
+
+type Subscription struct {
+	C <-chan Event
+
+	events chan Event
+	topics []string
+	broker *Local
+	once   sync.Once
+}
+
+// Close stops the delivery of events, C is closed once the subscription is removed
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.unsubscribe(s)
+	})
+}
+
+// Local is an in-process PubSub, it only reaches subscribers of the same instance
+type Local struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewLocal() *Local {
+	return &Local{subscribers: map[string]map[*Subscription]struct{}{}}
+}
+
+func (l *Local) Publish(ctx context.Context, topic, eventType string, data any) error {
+
+	event, err := newEvent(topic, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	l.dispatch(event)
+	return nil
+}
+
+func (l *Local) Subscribe(topics ...string) *Subscription {
+
+	events := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: events, events: events, topics: topics, broker: l}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, topic := range topics {
+		if l.subscribers[topic] == nil {
+			l.subscribers[topic] = map[*Subscription]struct{}{}
+		}
+		l.subscribers[topic][sub] = struct{}{}
+	}
+
+	return sub
+}
+
+func (l *Local) Close() error {
+	return nil
+}
+
+func (l *Local) unsubscribe(sub *Subscription) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, topic := range sub.topics {
+		delete(l.subscribers[topic], sub)
+		if len(l.subscribers[topic]) == 0 {
+			delete(l.subscribers, topic)
+		}
+	}
+
+	close(sub.events)
+}
+
+// dispatch hands the event to the subscribers of its topic, full subscriptions drop it
+func (l *Local) dispatch(event Event) {
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for sub := range l.subscribers[event.Topic] {
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const postgresChannel = "social_events"
+
+// Postgres shares events between instances through LISTEN/NOTIFY, every instance
+// listens on the same channel and dispatches what it receives to its local subscribers.
+// NOTIFY payloads are limited to 8000 bytes so events should carry ids rather than records
+type Postgres struct {
+	db       *sql.DB
+	listener *pq.Listener
+	local    *Local
+}
+
+func NewPostgres(db *sql.DB, addr string) (*Postgres, error) {
+
+	listener := pq.NewListener(addr, time.Second*10, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("pubsub listener error: ", err.Error())
+		}
+	})
+
+	if err := listener.Listen(postgresChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	p := &Postgres{db: db, listener: listener, local: NewLocal()}
+	go p.listen()
+
+	return p, nil
+}
+
+func (p *Postgres) Publish(ctx context.Context, topic, eventType string, data any) error {
+
+	event, err := newEvent(topic, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, postgresChannel, string(payload))
+	return err
+}
+
+func (p *Postgres) Subscribe(topics ...string) *Subscription {
+	return p.local.Subscribe(topics...)
+}
+
+func (p *Postgres) Close() error {
+	return p.listener.Close()
+}
+
+func (p *Postgres) listen() {
+
+	for {
+		select {
+		case notification, ok := <-p.listener.NotificationChannel():
+			if !ok {
+				return
+			}
+
+			// a nil notification tells the connection was reestablished, events sent meanwhile are lost
+			if notification == nil {
+				continue
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				log.Println("pubsub invalid event: ", err.Error())
+				continue
+			}
+
+			p.local.dispatch(event)
+
+		// an idle connection may be dropped silently, pinging makes the listener notice and reconnect
+		case <-time.After(time.Minute):
+			go p.listener.Ping()
+		}
+	}
+}
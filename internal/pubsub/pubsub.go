@@ -0,0 +1,47 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Event is a message published on a topic, Data is the json encoded payload
+type Event struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// PubSub delivers the events published on a topic to its subscribers,
+// delivery is best effort and slow subscribers miss events instead of blocking publishers
+type PubSub interface {
+	Publish(ctx context.Context, topic, eventType string, data any) error
+	Subscribe(topics ...string) *Subscription
+	Close() error
+}
+
+// events for a user, e.g. its notifications
+func UserTopic(userId int64) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+// events of an author for the feeds of its followers
+func AuthorTopic(userId int64) string {
+	return fmt.Sprintf("author:%d", userId)
+}
+
+// activity on a post, e.g. new comments
+func PostTopic(postId int64) string {
+	return fmt.Sprintf("post:%d", postId)
+}
+
+func newEvent(topic, eventType string, data any) (Event, error) {
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Topic: topic, Type: eventType, Data: payload}, nil
+}
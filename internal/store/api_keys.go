@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type IApiKeyStore interface {
+	Create(context.Context, *types.ApiKey) error
+	GetByPrefix(context.Context, string) (*types.ApiKey, error)
+	GetByUserId(context.Context, int64) ([]types.ApiKey, error)
+	Revoke(context.Context, int64, int64) error
+	Touch(context.Context, int64) error
+}
+
+type ApiKeyStore struct {
+	db *sql.DB
+}
+
+func (store *ApiKeyStore) Create(ctx context.Context, key *types.ApiKey) error {
+
+	query := `
+	INSERT INTO api_keys (user_id,name,prefix,key_hash,scopes,expires_at)
+	VALUES ($1,$2,$3,$4,$5,$6)
+	RETURNING id,created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := store.db.QueryRowContext(ctx,
+		query,
+		key.UserId,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		pq.Array(key.Scopes),
+		key.ExpiresAt,
+	).Scan(
+		&key.ID,
+		&key.CreatedAt,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetByPrefix returns the key only if it is neither revoked nor expired
+func (store *ApiKeyStore) GetByPrefix(ctx context.Context, prefix string) (*types.ApiKey, error) {
+
+	query := `
+	SELECT id,user_id,name,prefix,key_hash,scopes,expires_at,last_used_at,created_at
+	FROM api_keys
+	WHERE prefix = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	key := &types.ApiKey{}
+
+	err := store.db.QueryRowContext(ctx, query, prefix, time.Now()).Scan(
+		&key.ID,
+		&key.UserId,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (store *ApiKeyStore) GetByUserId(ctx context.Context, userId int64) ([]types.ApiKey, error) {
+
+	query := `
+	SELECT id,user_id,name,prefix,scopes,expires_at,last_used_at,revoked_at,created_at
+	FROM api_keys
+	WHERE user_id = $1
+	ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []types.ApiKey{}
+
+	for rows.Next() {
+		var k types.ApiKey
+
+		err := rows.Scan(
+			&k.ID,
+			&k.UserId,
+			&k.Name,
+			&k.Prefix,
+			pq.Array(&k.Scopes),
+			&k.ExpiresAt,
+			&k.LastUsedAt,
+			&k.RevokedAt,
+			&k.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (store *ApiKeyStore) Revoke(ctx context.Context, keyId int64, userId int64) error {
+
+	query := `
+	UPDATE api_keys SET revoked_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, keyId, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (store *ApiKeyStore) Touch(ctx context.Context, keyId int64) error {
+
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, keyId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
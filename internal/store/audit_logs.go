@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+	"time"
+)
+
+// IAuditLogStore is append only, entries are never updated
+// and only removed in bulk by the retention policy
+type IAuditLogStore interface {
+	Create(context.Context, *types.AuditLog) error
+	GetAll(context.Context, AuditLogQuery) ([]types.AuditLog, error)
+	DeleteOlderThan(context.Context, time.Time) (int64, error)
+}
+
+type AuditLogStore struct {
+	db *sql.DB
+}
+
+func (store *AuditLogStore) Create(ctx context.Context, entry *types.AuditLog) error {
+
+	query := `
+	INSERT INTO audit_logs (actor_id,action,target_type,target_id,before,after,request_id,ip)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	RETURNING id,created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := store.db.QueryRowContext(ctx,
+		query,
+		entry.ActorId,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetId,
+		nullJSON(entry.Before),
+		nullJSON(entry.After),
+		entry.RequestId,
+		entry.IP,
+	).Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (store *AuditLogStore) GetAll(ctx context.Context, fq AuditLogQuery) ([]types.AuditLog, error) {
+
+	query := `
+	SELECT id,actor_id,action,target_type,target_id,before,after,request_id,ip,created_at
+	FROM audit_logs
+	WHERE
+		($1 = 0 OR actor_id = $1) AND
+		($2 = '' OR action = $2) AND
+		($3 = '' OR target_type = $3) AND
+		($4 = 0 OR target_id = $4) AND
+		($5 = '' OR created_at >= $5::timestamptz) AND
+		($6 = '' OR created_at <= $6::timestamptz)
+	ORDER BY created_at DESC, id DESC
+	LIMIT $7 OFFSET $8
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		fq.ActorId,
+		fq.Action,
+		fq.TargetType,
+		fq.TargetId,
+		fq.Since,
+		fq.Until,
+		fq.Limit,
+		fq.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []types.AuditLog{}
+
+	for rows.Next() {
+		var e types.AuditLog
+		var before, after []byte
+
+		err := rows.Scan(
+			&e.ID,
+			&e.ActorId,
+			&e.Action,
+			&e.TargetType,
+			&e.TargetId,
+			&before,
+			&after,
+			&e.RequestId,
+			&e.IP,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Before = before
+		e.After = after
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func (store *AuditLogStore) DeleteOlderThan(ctx context.Context, t time.Time) (int64, error) {
+
+	query := `DELETE FROM audit_logs WHERE created_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.RowsAffected()
+}
+
+// nullJSON stores empty json documents as NULL instead of an invalid jsonb value
+func nullJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+
+	"github.com/lib/pq"
+)
+
+type IBookmarkStore interface {
+	Create(ctx context.Context, userId int64, postId int64) error
+	Delete(ctx context.Context, userId int64, postId int64) error
+	Exists(ctx context.Context, userId int64, postId int64) (bool, error)
+	GetByUserId(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error)
+}
+
+// BookmarkStore keeps the private bookmarks of users, only their owner ever reads them
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+func (store *BookmarkStore) Create(ctx context.Context, userId int64, postId int64) error {
+
+	query := `INSERT INTO bookmarks (user_id,post_id) VALUES ($1,$2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, userId, postId)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (store *BookmarkStore) Delete(ctx context.Context, userId int64, postId int64) error {
+
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, userId, postId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (store *BookmarkStore) Exists(ctx context.Context, userId int64, postId int64) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM bookmarks WHERE user_id = $1 AND post_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := store.db.QueryRowContext(ctx, query, userId, postId).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// GetByUserId pages through the bookmarks of the viewer, sorted by the time they were bookmarked.
+// posts the viewer can no longer see are left out
+func (store *BookmarkStore) GetByUserId(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error) {
+
+	query := `
+	SELECT p.id,p.user_id,u.username,p.title,p.content,p.created_at,p.version,p.tags,p.visibility,p.edited_at,p.quote_post_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id) AS reposts_count,
+	` + mentionsSQL("post_mentions", "post_id", "p.id") + `
+	FROM bookmarks b
+	JOIN posts p ON p.id = b.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		b.user_id = $1 AND
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
+		` + visiblePostSQL("$1", "$5", "p") + `
+	ORDER BY b.created_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		var p types.PostWithMetadata
+		var usernames []string
+
+		err := rows.Scan(
+			&p.ID,
+			&p.UserId,
+			&p.User.Username,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.EditedAt,
+			&p.QuotePostId,
+			&p.CommentsCount,
+			&p.RepostsCount,
+			pq.Array(&p.MentionedUserIds),
+			pq.Array(&usernames),
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Edited = p.EditedAt != nil
+		p.Entities = mentionEntities(p.Content, p.MentionedUserIds, usernames)
+		p.Bookmarked = true
+		bookmarks = append(bookmarks, p)
+	}
+
+	return bookmarks, rows.Err()
+}
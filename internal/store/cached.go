@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"social/internal/cache"
+	"social/internal/types"
+	"time"
+)
+
+// UseCache puts the users and posts read on every request behind the cache,
+// it has to be called before the stores are handed out
+func (s *Storage) UseCache(c cache.Cache, ttl time.Duration) {
+
+	loader := cache.NewLoader(c, ttl)
+
+	users := &CachedUserStore{IUserStore: s.Users, loader: loader}
+
+	s.Users = users
+	s.Posts = &CachedPostStore{IPostStore: s.Posts, users: users, loader: loader}
+}
+
+func userCacheKey(userId int64) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+func postCacheKey(postId int64) string {
+	return fmt.Sprintf("post:%d", postId)
+}
+
+// CachedUserStore caches GetById and drops the cached user on every write to it.
+// cached users never carry the password hash, logins read it through GetByEmail
+type CachedUserStore struct {
+	IUserStore
+	loader *cache.Loader
+}
+
+func (store *CachedUserStore) GetById(ctx context.Context, userId int64) (*types.User, error) {
+
+	return cache.Load(ctx, store.loader, userCacheKey(userId), func(ctx context.Context) (*types.User, error) {
+
+		user, err := store.IUserStore.GetById(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Password = ""
+
+		return user, nil
+	})
+}
+
+func (store *CachedUserStore) Delete(ctx context.Context, userId int64) error {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.Delete(ctx, userId)
+}
+
+func (store *CachedUserStore) SetRole(ctx context.Context, userId int64, role string) error {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.SetRole(ctx, userId, role)
+}
+
+func (store *CachedUserStore) SetActive(ctx context.Context, userId int64, active bool) error {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.SetActive(ctx, userId, active)
+}
+
+func (store *CachedUserStore) RevokeTokens(ctx context.Context, userId int64) error {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.RevokeTokens(ctx, userId)
+}
+
+func (store *CachedUserStore) Warn(ctx context.Context, userId int64) error {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.Warn(ctx, userId)
+}
+
+func (store *CachedUserStore) Suspend(ctx context.Context, userId int64, until time.Time) error {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.Suspend(ctx, userId, until)
+}
+
+func (store *CachedUserStore) SetPrivate(ctx context.Context, userId int64, private bool) ([]int64, error) {
+	defer store.loader.Invalidate(ctx, userCacheKey(userId))
+	return store.IUserStore.SetPrivate(ctx, userId, private)
+}
+
+// CachedPostStore caches GetPostById and drops the cached post on every write to it.
+// the author is read through the user cache so a change of its privacy applies at once
+type CachedPostStore struct {
+	IPostStore
+	users  IUserStore
+	loader *cache.Loader
+}
+
+func (store *CachedPostStore) GetPostById(ctx context.Context, postId int64) (*types.Post, error) {
+
+	post, err := cache.Load(ctx, store.loader, postCacheKey(postId), func(ctx context.Context) (*types.Post, error) {
+		return store.IPostStore.GetPostById(ctx, postId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := store.users.GetById(ctx, post.UserId)
+	if err != nil {
+		// inactive authors are not cached, their posts are read as they are
+		if errors.Is(err, ErrNotFound) {
+			return store.IPostStore.GetPostById(ctx, postId)
+		}
+		return nil, err
+	}
+
+	post.User.ID = author.ID
+	post.User.Username = author.Username
+	post.User.IsPrivate = author.IsPrivate
+
+	return post, nil
+}
+
+func (store *CachedPostStore) Update(ctx context.Context, post *types.Post) error {
+	defer store.loader.Invalidate(ctx, postCacheKey(post.ID))
+	return store.IPostStore.Update(ctx, post)
+}
+
+func (store *CachedPostStore) Delete(ctx context.Context, postId int64, version *int, deletedBy int64) error {
+	defer store.loader.Invalidate(ctx, postCacheKey(postId))
+	return store.IPostStore.Delete(ctx, postId, version, deletedBy)
+}
+
+func (store *CachedPostStore) Restore(ctx context.Context, postId int64, deletedSince time.Time) error {
+	defer store.loader.Invalidate(ctx, postCacheKey(postId))
+	return store.IPostStore.Restore(ctx, postId, deletedSince)
+}
+
+func (store *CachedPostStore) SetHidden(ctx context.Context, postId int64, hidden bool) error {
+	defer store.loader.Invalidate(ctx, postCacheKey(postId))
+	return store.IPostStore.SetHidden(ctx, postId, hidden)
+}
+
+func (store *CachedPostStore) Schedule(ctx context.Context, postId int64, publishAt *time.Time) error {
+	defer store.loader.Invalidate(ctx, postCacheKey(postId))
+	return store.IPostStore.Schedule(ctx, postId, publishAt)
+}
+
+func (store *CachedPostStore) Publish(ctx context.Context, postId int64) error {
+	defer store.loader.Invalidate(ctx, postCacheKey(postId))
+	return store.IPostStore.Publish(ctx, postId)
+}
+
+func (store *CachedPostStore) PublishDue(ctx context.Context) ([]int64, error) {
+
+	ids, err := store.IPostStore.PublishDue(ctx)
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = postCacheKey(id)
+	}
+	if len(keys) > 0 {
+		store.loader.Invalidate(ctx, keys...)
+	}
+
+	return ids, err
+}
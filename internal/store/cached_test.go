@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"social/internal/cache"
+	"social/internal/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUserStore blocks the next GetById after it read the user until release is closed
+type fakeUserStore struct {
+	IUserStore
+
+	mu      sync.Mutex
+	user    types.User
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *fakeUserStore) GetById(ctx context.Context, userId int64) (*types.User, error) {
+
+	s.mu.Lock()
+	user := s.user
+	started, release := s.started, s.release
+	s.started, s.release = nil, nil
+	s.mu.Unlock()
+
+	if release != nil {
+		close(started)
+		<-release
+	}
+
+	return &user, nil
+}
+
+func (s *fakeUserStore) Suspend(ctx context.Context, userId int64, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user.SuspendedUntil = &until
+	return nil
+}
+
+func (s *fakeUserStore) RevokeTokens(ctx context.Context, userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.user.TokensRevokedAt = &now
+	return nil
+}
+
+func (s *fakeUserStore) SetRole(ctx context.Context, userId int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user.Role.Name = role
+	return nil
+}
+
+func TestCachedUserStoreWriteDuringLoad(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		write   func(ctx context.Context, users IUserStore) error
+		applied func(user *types.User) bool
+	}{
+		{
+			"suspend",
+			func(ctx context.Context, users IUserStore) error {
+				return users.Suspend(ctx, 1, time.Now().Add(time.Hour))
+			},
+			func(user *types.User) bool { return user.SuspendedUntil != nil },
+		},
+		{
+			"revoke tokens",
+			func(ctx context.Context, users IUserStore) error {
+				return users.RevokeTokens(ctx, 1)
+			},
+			func(user *types.User) bool { return user.TokensRevokedAt != nil },
+		},
+		{
+			"demote",
+			func(ctx context.Context, users IUserStore) error {
+				return users.SetRole(ctx, 1, "user")
+			},
+			func(user *types.User) bool { return user.Role.Name == "user" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx := context.Background()
+
+			fake := &fakeUserStore{
+				user:    types.User{ID: 1, Role: types.Role{Name: "admin"}},
+				started: make(chan struct{}),
+				release: make(chan struct{}),
+			}
+			started, release := fake.started, fake.release
+
+			s := &Storage{Users: fake}
+			s.UseCache(cache.NewLRU(10), time.Minute)
+
+			// a request reads the user right before the write and gets its result after it
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if _, err := s.Users.GetById(ctx, 1); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			<-started
+			if err := tt.write(ctx, s.Users); err != nil {
+				t.Fatal(err)
+			}
+			close(release)
+			<-done
+
+			user, err := s.Users.GetById(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.applied(user) {
+				t.Errorf("expected the write to be visible, got the user read before it: %+v", user)
+			}
+		})
+	}
+}
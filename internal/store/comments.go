@@ -3,31 +3,113 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"social/internal/types"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type ICommentStore interface {
-	GetByPostId(ctx context.Context, postId int64) ([]types.Comment, error)
+	Create(ctx context.Context, comment *types.Comment) error
+	Update(ctx context.Context, comment *types.Comment) error
+	GetByPostId(ctx context.Context, postId int64, viewer Viewer) ([]types.Comment, error)
+	GetById(ctx context.Context, commentId int64) (*types.Comment, error)
+	SetHidden(ctx context.Context, commentId int64, hidden bool) error
+	Delete(ctx context.Context, commentId int64, deletedBy int64) error
+	GetDeletedById(ctx context.Context, commentId int64) (*types.Comment, error)
+	Restore(ctx context.Context, commentId int64, deletedSince time.Time) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
 }
 
 type CommentsStore struct {
 	db *sql.DB
 }
 
-func (store *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]types.Comment, error) {
+func (store *CommentsStore) Create(ctx context.Context, comment *types.Comment) error {
+
+	query := `
+	INSERT INTO comments (post_id,user_id,content)
+	VALUES ($1,$2,$3) RETURNING id,created_at
+	`
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, comment.PostId, comment.UserId, comment.Content).Scan(
+			&comment.ID,
+			&comment.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		return store.syncMentions(ctx, tx, comment)
+	})
+}
+
+func (store *CommentsStore) Update(ctx context.Context, comment *types.Comment) error {
+
+	query := `
+	UPDATE comments SET content = $2
+	WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		response, err := tx.ExecContext(ctx, query, comment.ID, comment.Content)
+		if err != nil {
+			return err
+		}
+
+		rows, err := response.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return store.syncMentions(ctx, tx, comment)
+	})
+}
+
+// syncMentions replaces the mentions of the comment with the users mentioned in its content
+func (store *CommentsStore) syncMentions(ctx context.Context, tx *sql.Tx, comment *types.Comment) error {
+
+	userIds, usernames, err := syncMentions(ctx, tx, "comment_mentions", "comment_id", comment.ID, comment.UserId, comment.Content)
+	if err != nil {
+		return err
+	}
+
+	comment.MentionedUserIds = userIds
+	comment.Entities = mentionEntities(comment.Content, userIds, usernames)
+
+	return nil
+}
+
+func (store *CommentsStore) GetByPostId(ctx context.Context, postId int64, viewer Viewer) ([]types.Comment, error) {
 
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id 
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.hidden_at, users.username, users.id,
+		` + mentionsSQL("comment_mentions", "comment_id", "c.id") + `
 		FROM comments c
 		JOIN users ON users.id = c.user_id
-		WHERE c.post_id = $1 
+		WHERE c.post_id = $1 AND c.deleted_at IS NULL AND ($2 OR c.hidden_at IS NULL) AND
+		` + notBlockedSQL("$3", "c.user_id") + `
 		ORDER BY c.created_at DESC
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := store.db.QueryContext(ctx, query, postId)
+	rows, err := store.db.QueryContext(ctx, query, postId, viewer.Moderator, viewer.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +120,7 @@ func (store *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]ty
 
 	for rows.Next() {
 		var c types.Comment
+		var usernames []string
 		c.User = types.User{}
 
 		err := rows.Scan(
@@ -46,15 +129,197 @@ func (store *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]ty
 			&c.UserId,
 			&c.Content,
 			&c.CreatedAt,
+			&c.HiddenAt,
 			&c.User.Username,
 			&c.User.ID,
+			pq.Array(&c.MentionedUserIds),
+			pq.Array(&usernames),
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		c.Entities = mentionEntities(c.Content, c.MentionedUserIds, usernames)
+
 		comments = append(comments, c)
 	}
 
 	return comments, nil
 }
+
+func (store *CommentsStore) GetById(ctx context.Context, commentId int64) (*types.Comment, error) {
+
+	query := `
+	SELECT c.id,c.post_id,c.user_id,c.content,c.created_at,c.hidden_at,
+	` + mentionsSQL("comment_mentions", "comment_id", "c.id") + `
+	FROM comments c
+	WHERE c.id = $1 AND c.deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c types.Comment
+	var usernames []string
+
+	err := store.db.QueryRowContext(ctx, query, commentId).Scan(
+		&c.ID,
+		&c.PostId,
+		&c.UserId,
+		&c.Content,
+		&c.CreatedAt,
+		&c.HiddenAt,
+		pq.Array(&c.MentionedUserIds),
+		pq.Array(&usernames),
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	c.Entities = mentionEntities(c.Content, c.MentionedUserIds, usernames)
+
+	return &c, nil
+}
+
+func (store *CommentsStore) SetHidden(ctx context.Context, commentId int64, hidden bool) error {
+
+	query := `
+	UPDATE comments SET hidden_at = CASE WHEN $2 THEN NOW() ELSE NULL END
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, commentId, hidden)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete marks the comment as deleted, it stays restorable until PurgeDeleted removes it
+func (store *CommentsStore) Delete(ctx context.Context, commentId int64, deletedBy int64) error {
+
+	query := `
+	UPDATE comments SET deleted_at = NOW(), deleted_by = $2
+	WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, commentId, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDeletedById returns a comment deleted but not yet purged
+func (store *CommentsStore) GetDeletedById(ctx context.Context, commentId int64) (*types.Comment, error) {
+
+	query := `
+	SELECT id,post_id,user_id,content,created_at,hidden_at,deleted_at,deleted_by
+	FROM comments
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c types.Comment
+
+	err := store.db.QueryRowContext(ctx, query, commentId).Scan(
+		&c.ID,
+		&c.PostId,
+		&c.UserId,
+		&c.Content,
+		&c.CreatedAt,
+		&c.HiddenAt,
+		&c.DeletedAt,
+		&c.DeletedBy,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// Restore brings back a comment deleted after deletedSince
+func (store *CommentsStore) Restore(ctx context.Context, commentId int64, deletedSince time.Time) error {
+
+	query := `
+	UPDATE comments SET deleted_at = NULL, deleted_by = NULL
+	WHERE id = $1 AND deleted_at >= $2 AND
+	NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = comments.post_id AND p.deleted_at IS NOT NULL)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, commentId, deletedSince)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted removes the comments deleted before the given time
+func (store *CommentsStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+
+	query := `DELETE FROM comments WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.RowsAffected()
+}
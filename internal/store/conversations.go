@@ -0,0 +1,404 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"social/internal/types"
+
+	"github.com/lib/pq"
+)
+
+type IConversationStore interface {
+	GetOrCreateDirect(ctx context.Context, userId int64, otherId int64) (*types.Conversation, bool, error)
+	CreateGroup(ctx context.Context, conversation *types.Conversation, participantIds []int64) error
+	GetById(ctx context.Context, conversationId int64, userId int64) (*types.Conversation, error)
+	GetByUserId(ctx context.Context, userId int64, cq ConversationQuery) ([]types.Conversation, error)
+	CreateMessage(ctx context.Context, message *types.Message) error
+	GetMessages(ctx context.Context, conversationId int64, userId int64, mq MessageQuery) ([]types.Message, error)
+	MarkRead(ctx context.Context, conversationId int64, userId int64, messageId int64) (int64, error)
+}
+
+// ConversationStore keeps the direct messages, every read is made for a participant
+// and leaves out the messages of users blocked by or blocking it
+type ConversationStore struct {
+	db *sql.DB
+}
+
+// GetOrCreateDirect returns the one-to-one conversation of two users, created tells whether it is new
+func (store *ConversationStore) GetOrCreateDirect(ctx context.Context, userId int64, otherId int64) (*types.Conversation, bool, error) {
+
+	directKey := fmt.Sprintf("%d:%d", min(userId, otherId), max(userId, otherId))
+
+	var conversationId int64
+	created := true
+
+	err := withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO conversations (direct_key,created_by) VALUES ($1,$2)
+		ON CONFLICT (direct_key) DO NOTHING
+		RETURNING id
+		`
+
+		err := tx.QueryRowContext(ctx, query, directKey, userId).Scan(&conversationId)
+		if errors.Is(err, sql.ErrNoRows) {
+			created = false
+			return tx.QueryRowContext(ctx, `SELECT id FROM conversations WHERE direct_key = $1`, directKey).Scan(&conversationId)
+		}
+		if err != nil {
+			return err
+		}
+
+		return addParticipants(ctx, tx, conversationId, []int64{userId, otherId})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	conversation, err := store.GetById(ctx, conversationId, userId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return conversation, created, nil
+}
+
+// CreateGroup creates a group conversation, participantIds includes its creator
+func (store *ConversationStore) CreateGroup(ctx context.Context, conversation *types.Conversation, participantIds []int64) error {
+
+	err := withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO conversations (is_group,title,created_by) VALUES (true,$1,$2)
+		RETURNING id,created_at,updated_at
+		`
+
+		err := tx.QueryRowContext(ctx, query, conversation.Title, conversation.CreatedBy).Scan(
+			&conversation.ID,
+			&conversation.CreatedAt,
+			&conversation.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		return addParticipants(ctx, tx, conversation.ID, participantIds)
+	})
+	if err != nil {
+		return err
+	}
+
+	conversation.IsGroup = true
+
+	participants, err := store.getParticipants(ctx, []int64{conversation.ID})
+	if err != nil {
+		return err
+	}
+	conversation.Participants = participants[conversation.ID]
+
+	return nil
+}
+
+func addParticipants(ctx context.Context, tx *sql.Tx, conversationId int64, userIds []int64) error {
+
+	query := `
+	INSERT INTO conversation_participants (conversation_id,user_id)
+	SELECT $1, unnest($2::bigint[])
+	ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.ExecContext(ctx, query, conversationId, pq.Array(userIds))
+	return err
+}
+
+// GetById returns the conversation if userId takes part in it
+func (store *ConversationStore) GetById(ctx context.Context, conversationId int64, userId int64) (*types.Conversation, error) {
+
+	query := `
+	SELECT c.id,c.is_group,c.title,c.created_by,c.created_at,c.updated_at
+	FROM conversations c
+	JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id = $2
+	WHERE c.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var conversation types.Conversation
+
+	err := store.db.QueryRowContext(ctx, query, conversationId, userId).Scan(
+		&conversation.ID,
+		&conversation.IsGroup,
+		&conversation.Title,
+		&conversation.CreatedBy,
+		&conversation.CreatedAt,
+		&conversation.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	participants, err := store.getParticipants(ctx, []int64{conversation.ID})
+	if err != nil {
+		return nil, err
+	}
+	conversation.Participants = participants[conversation.ID]
+
+	return &conversation, nil
+}
+
+// GetByUserId pages through the conversations of a user, the most recently active first
+func (store *ConversationStore) GetByUserId(ctx context.Context, userId int64, cq ConversationQuery) ([]types.Conversation, error) {
+
+	query := `
+	SELECT c.id,c.is_group,c.title,c.created_by,c.created_at,c.updated_at,
+	lm.id,lm.sender_id,lm.username,lm.content,lm.created_at,
+	(
+		SELECT COUNT(*) FROM messages m
+		WHERE m.conversation_id = c.id AND m.id > COALESCE(cp.last_read_message_id, 0) AND m.sender_id <> $1 AND
+		` + notBlockedSQL("$1", "m.sender_id") + `
+	) AS unread_count
+	FROM conversation_participants cp
+	JOIN conversations c ON c.id = cp.conversation_id
+	LEFT JOIN LATERAL (
+		SELECT m.id,m.sender_id,u.username,m.content,m.created_at
+		FROM messages m
+		JOIN users u ON u.id = m.sender_id
+		WHERE m.conversation_id = c.id AND ` + notBlockedSQL("$1", "m.sender_id") + `
+		ORDER BY m.id DESC
+		LIMIT 1
+	) lm ON true
+	WHERE cp.user_id = $1
+	ORDER BY c.updated_at DESC, c.id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, userId, cq.Limit, cq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []types.Conversation{}
+	ids := []int64{}
+
+	for rows.Next() {
+		var c types.Conversation
+		var lastId, lastSenderId sql.NullInt64
+		var lastUsername, lastContent, lastCreatedAt sql.NullString
+
+		err := rows.Scan(
+			&c.ID,
+			&c.IsGroup,
+			&c.Title,
+			&c.CreatedBy,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&lastId,
+			&lastSenderId,
+			&lastUsername,
+			&lastContent,
+			&lastCreatedAt,
+			&c.UnreadCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if lastId.Valid {
+			c.LastMessage = &types.Message{
+				ID:             lastId.Int64,
+				ConversationId: c.ID,
+				SenderId:       lastSenderId.Int64,
+				Sender:         types.User{ID: lastSenderId.Int64, Username: lastUsername.String},
+				Content:        lastContent.String,
+				CreatedAt:      lastCreatedAt.String,
+			}
+		}
+
+		conversations = append(conversations, c)
+		ids = append(ids, c.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	participants, err := store.getParticipants(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conversations {
+		conversations[i].Participants = participants[conversations[i].ID]
+	}
+
+	return conversations, nil
+}
+
+// getParticipants returns the participants of the conversations with their read receipts, by conversation id
+func (store *ConversationStore) getParticipants(ctx context.Context, conversationIds []int64) (map[int64][]types.ConversationParticipant, error) {
+
+	query := `
+	SELECT cp.conversation_id,u.id,u.username,cp.last_read_message_id,cp.last_read_at
+	FROM conversation_participants cp
+	JOIN users u ON u.id = cp.user_id
+	WHERE cp.conversation_id = ANY($1)
+	ORDER BY cp.joined_at, u.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, pq.Array(conversationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := map[int64][]types.ConversationParticipant{}
+
+	for rows.Next() {
+		var conversationId int64
+		var p types.ConversationParticipant
+
+		if err := rows.Scan(&conversationId, &p.UserId, &p.Username, &p.LastReadMessageId, &p.LastReadAt); err != nil {
+			return nil, err
+		}
+		participants[conversationId] = append(participants[conversationId], p)
+	}
+
+	return participants, rows.Err()
+}
+
+// CreateMessage stores a message, bumps its conversation to the top of the lists
+// and marks it as read by its sender
+func (store *ConversationStore) CreateMessage(ctx context.Context, message *types.Message) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO messages (conversation_id,sender_id,content) VALUES ($1,$2,$3)
+		RETURNING id,created_at
+		`
+
+		err := tx.QueryRowContext(ctx, query, message.ConversationId, message.SenderId, message.Content).Scan(
+			&message.ID,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE conversations SET updated_at = NOW() WHERE id = $1`, message.ConversationId); err != nil {
+			return err
+		}
+
+		query = `
+		UPDATE conversation_participants SET last_read_message_id = $3, last_read_at = NOW()
+		WHERE conversation_id = $1 AND user_id = $2
+		`
+
+		_, err = tx.ExecContext(ctx, query, message.ConversationId, message.SenderId, message.ID)
+		return err
+	})
+}
+
+// GetMessages returns a page of the history of a conversation, newest first
+func (store *ConversationStore) GetMessages(ctx context.Context, conversationId int64, userId int64, mq MessageQuery) ([]types.Message, error) {
+
+	query := `
+	SELECT m.id,m.conversation_id,m.sender_id,u.username,m.content,m.created_at
+	FROM messages m
+	JOIN users u ON u.id = m.sender_id
+	WHERE
+		m.conversation_id = $1 AND
+		($3::bigint = 0 OR m.id < $3) AND
+		` + notBlockedSQL("$2", "m.sender_id") + `
+	ORDER BY m.id DESC
+	LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, conversationId, userId, mq.Before, mq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []types.Message{}
+
+	for rows.Next() {
+		var m types.Message
+
+		err := rows.Scan(
+			&m.ID,
+			&m.ConversationId,
+			&m.SenderId,
+			&m.Sender.Username,
+			&m.Content,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		m.Sender.ID = m.SenderId
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkRead moves the read receipt of a participant up to messageId, or to the latest message when
+// it is 0, and returns the message the receipt is at. receipts never move backwards
+func (store *ConversationStore) MarkRead(ctx context.Context, conversationId int64, userId int64, messageId int64) (int64, error) {
+
+	query := `
+	UPDATE conversation_participants cp
+	SET last_read_message_id = GREATEST(COALESCE(cp.last_read_message_id, 0), m.id), last_read_at = NOW()
+	FROM (
+		SELECT id FROM messages
+		WHERE conversation_id = $1 AND ($3::bigint = 0 OR id = $3)
+		ORDER BY id DESC
+		LIMIT 1
+	) m
+	WHERE cp.conversation_id = $1 AND cp.user_id = $2
+	RETURNING cp.last_read_message_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var lastRead int64
+
+	err := store.db.QueryRowContext(ctx, query, conversationId, userId, messageId).Scan(&lastRead)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return lastRead, nil
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type IExploreStore interface {
+	GetGlobal(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error)
+	GetPopular(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery, window time.Duration, halfLife time.Duration) ([]types.PostWithMetadata, error)
+}
+
+// ExploreStore lists the posts of everyone, not only of the followed users. anonymous viewers
+// have a zero id, for them visiblePostSQL leaves only public posts of public accounts
+type ExploreStore struct {
+	db *sql.DB
+}
+
+// exploreSQL selects the visible posts matching the tags, search and time window of the query,
+// the caller appends the order
+func exploreSQL() string {
+	return `
+	SELECT p.id,p.user_id,u.username,p.title,p.content,p.created_at,p.version,p.tags,p.visibility,p.edited_at,p.quote_post_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id) AS reposts_count,
+	EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $1) AS bookmarked,
+	` + mentionsSQL("post_mentions", "post_id", "p.id") + `
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		p.tags @> $6::varchar(100)[] AND
+		($7 = '' OR p.created_at >= $7::timestamptz) AND
+		($8 = '' OR p.created_at <= $8::timestamptz) AND
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
+		` + visiblePostSQL("$1", "$5", "p") + ` AND
+		` + notMutedSQL("$1", "p.user_id")
+}
+
+// GetGlobal returns the latest posts of everyone
+func (store *ExploreStore) GetGlobal(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error) {
+
+	query := exploreSQL() + `
+	ORDER BY p.created_at ` + fq.Sort + `, p.id ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	return store.list(ctx, query, viewer, fq)
+}
+
+// GetPopular returns the posts of the window with the most comments and reposts,
+// the weight of a post halves every half life. the window is the one of the query when it has one
+func (store *ExploreStore) GetPopular(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery, window time.Duration, halfLife time.Duration) ([]types.PostWithMetadata, error) {
+
+	query := exploreSQL() + ` AND
+		($7 <> '' OR p.created_at > NOW() - make_interval(secs => $9))
+	ORDER BY
+		(1 +
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) +
+			(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id)
+		) * POWER(0.5, EXTRACT(EPOCH FROM NOW() - p.created_at) / $10) DESC,
+		p.id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	return store.list(ctx, query, viewer, fq, window.Seconds(), halfLife.Seconds())
+}
+
+func (store *ExploreStore) list(ctx context.Context, query string, viewer Viewer, fq PaginatedFeedQuery, args ...any) ([]types.PostWithMetadata, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tags := fq.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	rows, err := store.db.QueryContext(ctx, query, append([]any{
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator,
+		pq.Array(tags),
+		fq.Since,
+		fq.Until,
+	}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		var p types.PostWithMetadata
+		var usernames []string
+
+		err := rows.Scan(
+			&p.ID,
+			&p.UserId,
+			&p.User.Username,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.EditedAt,
+			&p.QuotePostId,
+			&p.CommentsCount,
+			&p.RepostsCount,
+			&p.Bookmarked,
+			pq.Array(&p.MentionedUserIds),
+			pq.Array(&usernames),
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Edited = p.EditedAt != nil
+		p.Entities = mentionEntities(p.Content, p.MentionedUserIds, usernames)
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"social/internal/content"
+	"social/internal/types"
+
+	"github.com/lib/pq"
+)
+
+type IMentionStore interface {
+	GetPosts(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error)
+}
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// mentionsSQL selects the ids and usernames of the users mentioned by a record as two
+// arrays in the same order, table is the mentions table and column its reference to the record
+func mentionsSQL(table, column, record string) string {
+	return fmt.Sprintf(`
+	ARRAY(SELECT m.user_id FROM %[1]s m JOIN users mu ON mu.id = m.user_id WHERE m.%[2]s = %[3]s ORDER BY m.user_id),
+	ARRAY(SELECT mu.username FROM %[1]s m JOIN users mu ON mu.id = m.user_id WHERE m.%[2]s = %[3]s ORDER BY m.user_id)`,
+		table, column, record)
+}
+
+// mentionEntities locates the mentions of the resolved users in the text,
+// mentions of usernames that do not exist are left out
+func mentionEntities(text string, userIds []int64, usernames []string) types.Entities {
+
+	ids := make(map[string]int64, len(usernames))
+	for i, username := range usernames {
+		ids[username] = userIds[i]
+	}
+
+	entities := types.Entities{Mentions: []types.MentionEntity{}}
+
+	for _, span := range content.FindMentions(text) {
+		id, ok := ids[span.Username]
+		if !ok {
+			continue
+		}
+		entities.Mentions = append(entities.Mentions, types.MentionEntity{
+			UserId:   id,
+			Username: span.Username,
+			Start:    span.Start,
+			End:      span.End,
+		})
+	}
+
+	return entities
+}
+
+// syncMentions replaces the mentions stored for a record with the users mentioned in its text,
+// authors never mention themselves. it returns the mentioned users with their usernames
+func syncMentions(ctx context.Context, tx *sql.Tx, table, column string, recordId, authorId int64, text string) ([]int64, []string, error) {
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, table, column), recordId); err != nil {
+		return nil, nil, err
+	}
+
+	query := fmt.Sprintf(`
+	WITH inserted AS (
+		INSERT INTO %s (%s,user_id)
+		SELECT $1, id FROM users WHERE username = ANY($2) AND id <> $3
+		RETURNING user_id
+	)
+	SELECT i.user_id, u.username FROM inserted i JOIN users u ON u.id = i.user_id
+	`, table, column)
+
+	rows, err := tx.QueryContext(ctx, query, recordId, pq.Array(content.ExtractMentions(text)), authorId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	userIds := []int64{}
+	usernames := []string{}
+
+	for rows.Next() {
+		var userId int64
+		var username string
+		if err := rows.Scan(&userId, &username); err != nil {
+			return nil, nil, err
+		}
+		userIds = append(userIds, userId)
+		usernames = append(usernames, username)
+	}
+
+	return userIds, usernames, rows.Err()
+}
+
+// GetPosts pages through the posts that mention the viewer
+func (store *MentionStore) GetPosts(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error) {
+
+	query := `
+	SELECT p.id,p.user_id,u.username,p.title,p.content,p.created_at,p.version,p.tags,p.visibility,p.edited_at,p.quote_post_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id) AS reposts_count,
+	EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $1) AS bookmarked,
+	` + mentionsSQL("post_mentions", "post_id", "p.id") + `
+	FROM post_mentions pm
+	JOIN posts p ON p.id = pm.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		pm.user_id = $1 AND
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
+		` + visiblePostSQL("$1", "$5", "p") + ` AND
+		` + notMutedSQL("$1", "p.user_id") + `
+	ORDER BY p.created_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		var p types.PostWithMetadata
+		var usernames []string
+
+		err := rows.Scan(
+			&p.ID,
+			&p.UserId,
+			&p.User.Username,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.EditedAt,
+			&p.QuotePostId,
+			&p.CommentsCount,
+			&p.RepostsCount,
+			&p.Bookmarked,
+			pq.Array(&p.MentionedUserIds),
+			pq.Array(&usernames),
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Edited = p.EditedAt != nil
+		p.Entities = mentionEntities(p.Content, p.MentionedUserIds, usernames)
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
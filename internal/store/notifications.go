@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"social/internal/types"
+
+	"github.com/lib/pq"
+)
+
+type INotificationStore interface {
+	Create(ctx context.Context, notification *types.Notification) error
+	GetGrouped(ctx context.Context, userId int64, nq NotificationQuery) ([]types.NotificationGroup, error)
+	CountUnread(ctx context.Context, userId int64) (int, error)
+	MarkRead(ctx context.Context, userId int64, notificationId int64) error
+	MarkAllRead(ctx context.Context, userId int64) error
+	GetPreferences(ctx context.Context, userId int64) (map[string]bool, error)
+	SetPreference(ctx context.Context, userId int64, notificationType string, enabled bool) error
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// Create stores the notification unless the user turned its type off or blocked or muted the actor,
+// ErrNotFound tells the notification was dropped
+func (store *NotificationStore) Create(ctx context.Context, notification *types.Notification) error {
+
+	query := `
+	INSERT INTO notifications (user_id,actor_id,type,target_type,target_id)
+	SELECT $1,$2,$3,$4,$5
+	WHERE
+		NOT EXISTS (SELECT 1 FROM notification_preferences np WHERE np.user_id = $1 AND np.type = $3 AND NOT np.enabled) AND
+		` + notBlockedSQL("$1", "$2") + ` AND
+		` + notMutedSQL("$1", "$2") + `
+	RETURNING id,created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := store.db.QueryRowContext(ctx, query,
+		notification.UserId,
+		notification.ActorId,
+		notification.Type,
+		notification.TargetType,
+		notification.TargetId,
+	).Scan(&notification.ID, &notification.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetGrouped pages through the notifications of a user grouped by type and target,
+// read and unread notifications of the same target are separate groups
+func (store *NotificationStore) GetGrouped(ctx context.Context, userId int64, nq NotificationQuery) ([]types.NotificationGroup, error) {
+
+	query := `
+	WITH groups AS (
+		SELECT MAX(n.id) AS id, n.type, n.target_type, n.target_id, n.read_at IS NOT NULL AS read,
+		MAX(n.created_at) AS created_at, COUNT(DISTINCT n.actor_id) AS actors_count
+		FROM notifications n
+		WHERE n.user_id = $1 AND (NOT $4 OR n.read_at IS NULL)
+		GROUP BY n.type, n.target_type, n.target_id, n.read_at IS NOT NULL
+		ORDER BY MAX(n.created_at) DESC, MAX(n.id) DESC
+		LIMIT $2 OFFSET $3
+	)
+	SELECT g.id, g.type, g.target_type, g.target_id, g.read, g.created_at, g.actors_count,
+	ARRAY(
+		SELECT u.id FROM notifications an JOIN users u ON u.id = an.actor_id
+		WHERE an.user_id = $1 AND an.type = g.type AND an.target_type = g.target_type AND an.target_id = g.target_id AND (an.read_at IS NOT NULL) = g.read
+		GROUP BY u.id ORDER BY MAX(an.created_at) DESC LIMIT 3
+	),
+	ARRAY(
+		SELECT u.username FROM notifications an JOIN users u ON u.id = an.actor_id
+		WHERE an.user_id = $1 AND an.type = g.type AND an.target_type = g.target_type AND an.target_id = g.target_id AND (an.read_at IS NOT NULL) = g.read
+		GROUP BY u.id ORDER BY MAX(an.created_at) DESC LIMIT 3
+	)
+	FROM groups g
+	ORDER BY g.created_at DESC, g.id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, userId, nq.Limit, nq.Offset, nq.Unread)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []types.NotificationGroup{}
+
+	for rows.Next() {
+		var g types.NotificationGroup
+		var actorIds []int64
+		var actorNames []string
+
+		err := rows.Scan(
+			&g.ID,
+			&g.Type,
+			&g.TargetType,
+			&g.TargetId,
+			&g.Read,
+			&g.CreatedAt,
+			&g.ActorsCount,
+			pq.Array(&actorIds),
+			pq.Array(&actorNames),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		g.Actors = make([]types.User, len(actorIds))
+		for i := range actorIds {
+			g.Actors[i] = types.User{ID: actorIds[i], Username: actorNames[i]}
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+func (store *NotificationStore) CountUnread(ctx context.Context, userId int64) (int, error) {
+
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	if err := store.db.QueryRowContext(ctx, query, userId).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the notification and the rest of its group as read
+func (store *NotificationStore) MarkRead(ctx context.Context, userId int64, notificationId int64) error {
+
+	query := `
+	WITH target AS (
+		SELECT type,target_type,target_id FROM notifications WHERE id = $2 AND user_id = $1
+	), updated AS (
+		UPDATE notifications n SET read_at = NOW()
+		FROM target t
+		WHERE n.user_id = $1 AND n.read_at IS NULL AND
+		n.type = t.type AND n.target_type = t.target_type AND n.target_id = t.target_id
+	)
+	SELECT EXISTS (SELECT 1 FROM target)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := store.db.QueryRowContext(ctx, query, userId, notificationId).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (store *NotificationStore) MarkAllRead(ctx context.Context, userId int64) error {
+
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+// GetPreferences returns every notification type with whether the user receives it, types are on by default
+func (store *NotificationStore) GetPreferences(ctx context.Context, userId int64) (map[string]bool, error) {
+
+	query := `SELECT type,enabled FROM notification_preferences WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := make(map[string]bool, len(types.NotificationTypes))
+	for _, notificationType := range types.NotificationTypes {
+		preferences[notificationType] = true
+	}
+
+	for rows.Next() {
+		var notificationType string
+		var enabled bool
+		if err := rows.Scan(&notificationType, &enabled); err != nil {
+			return nil, err
+		}
+		preferences[notificationType] = enabled
+	}
+
+	return preferences, rows.Err()
+}
+
+func (store *NotificationStore) SetPreference(ctx context.Context, userId int64, notificationType string, enabled bool) error {
+
+	query := `
+	INSERT INTO notification_preferences (user_id,type,enabled) VALUES ($1,$2,$3)
+	ON CONFLICT (user_id,type) DO UPDATE SET enabled = EXCLUDED.enabled
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, userId, notificationType, enabled)
+	return err
+}
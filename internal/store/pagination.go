@@ -1,7 +1,6 @@
 package store
 
 import (
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -57,24 +56,162 @@ func (PaginatedFeedQuery PaginatedFeedQuery) Parse(req *http.Request) (Paginated
 		PaginatedFeedQuery.Search = search
 	}
 
-	since := qs.Get("since")
-	if since != "" {
-		log.Println("since:: ", since)
+	PaginatedFeedQuery.Since = parseTime(qs.Get("since"))
+	PaginatedFeedQuery.Until = parseTime(qs.Get("until"))
+
+	return PaginatedFeedQuery, nil
+
+}
+
+type UserFilterQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Role   string `json:"role" validate:"max=25"`
+	Active *bool  `json:"active"`
+	Search string `json:"search" validate:"max=100"`
+}
+
+func (UserFilterQuery UserFilterQuery) Parse(req *http.Request) (UserFilterQuery, error) {
+
+	// /admin/users?limit=50&offset=0&role=moderator&active=false&search=gopher
+	qs := req.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return UserFilterQuery, err
+		}
+		UserFilterQuery.Limit = l
 	}
 
-	until := qs.Get("until")
-	if until != "" {
-		log.Println("until:: ", until)
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return UserFilterQuery, err
+		}
+		UserFilterQuery.Offset = o
 	}
 
-	return PaginatedFeedQuery, nil
+	UserFilterQuery.Role = qs.Get("role")
+	UserFilterQuery.Search = qs.Get("search")
+
+	active := qs.Get("active")
+	if active != "" {
+		a, err := strconv.ParseBool(active)
+		if err != nil {
+			return UserFilterQuery, err
+		}
+		UserFilterQuery.Active = &a
+	}
+
+	return UserFilterQuery, nil
+}
+
+type AuditLogQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	ActorId    int64  `json:"actor_id" validate:"gte=0"`
+	Action     string `json:"action" validate:"max=100"`
+	TargetType string `json:"target_type" validate:"max=50"`
+	TargetId   int64  `json:"target_id" validate:"gte=0"`
+	Since      string `json:"since"`
+	Until      string `json:"until"`
+}
+
+func (AuditLogQuery AuditLogQuery) Parse(req *http.Request) (AuditLogQuery, error) {
+
+	// /admin/audit-logs?actor_id=1&action=post.delete&since=2024-01-01 00:00:00
+	qs := req.URL.Query()
+
+	ints := map[string]*int{
+		"limit":  &AuditLogQuery.Limit,
+		"offset": &AuditLogQuery.Offset,
+	}
+	for key, dest := range ints {
+		if v := qs.Get(key); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return AuditLogQuery, err
+			}
+			*dest = i
+		}
+	}
+
+	ids := map[string]*int64{
+		"actor_id":  &AuditLogQuery.ActorId,
+		"target_id": &AuditLogQuery.TargetId,
+	}
+	for key, dest := range ids {
+		if v := qs.Get(key); v != "" {
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return AuditLogQuery, err
+			}
+			*dest = i
+		}
+	}
+
+	AuditLogQuery.Action = qs.Get("action")
+	AuditLogQuery.TargetType = qs.Get("target_type")
+	AuditLogQuery.Since = parseTime(qs.Get("since"))
+	AuditLogQuery.Until = parseTime(qs.Get("until"))
+
+	return AuditLogQuery, nil
+}
+
+type ReportQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	Status     string `json:"status" validate:"oneof=open dismissed resolved"`
+	TargetType string `json:"target_type" validate:"omitempty,oneof=post comment"`
+	Reason     string `json:"reason" validate:"max=30"`
+}
+
+func (ReportQuery ReportQuery) Parse(req *http.Request) (ReportQuery, error) {
+
+	// /moderation/reports?status=open&target_type=post&reason=spam
+	qs := req.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return ReportQuery, err
+		}
+		ReportQuery.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return ReportQuery, err
+		}
+		ReportQuery.Offset = o
+	}
+
+	status := qs.Get("status")
+	if status != "" {
+		ReportQuery.Status = status
+	}
 
+	ReportQuery.TargetType = qs.Get("target_type")
+	ReportQuery.Reason = qs.Get("reason")
+
+	return ReportQuery, nil
 }
 
+// parseTime accepts "2006-01-02 15:04:05" and RFC3339 times, anything else is ignored
 func parseTime(s string) string {
 	t, err := time.Parse(time.DateTime, s)
 	if err != nil {
-		return ""
+		t, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
 	}
 	return t.Format(time.DateTime)
 }
@@ -96,3 +233,126 @@ func parseTime(s string) string {
 // 	}
 // 	return tagStrings
 // }
+
+type NotificationQuery struct {
+	Limit  int  `json:"limit" validate:"gte=1,lte=50"`
+	Offset int  `json:"offset" validate:"gte=0"`
+	Unread bool `json:"unread"`
+}
+
+func (NotificationQuery NotificationQuery) Parse(req *http.Request) (NotificationQuery, error) {
+
+	// /notifications?limit=20&offset=0&unread=true
+	qs := req.URL.Query()
+
+	ints := map[string]*int{
+		"limit":  &NotificationQuery.Limit,
+		"offset": &NotificationQuery.Offset,
+	}
+	for key, dest := range ints {
+		if v := qs.Get(key); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return NotificationQuery, err
+			}
+			*dest = i
+		}
+	}
+
+	if v := qs.Get("unread"); v != "" {
+		unread, err := strconv.ParseBool(v)
+		if err != nil {
+			return NotificationQuery, err
+		}
+		NotificationQuery.Unread = unread
+	}
+
+	return NotificationQuery, nil
+}
+
+type ConversationQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=50"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (ConversationQuery ConversationQuery) Parse(req *http.Request) (ConversationQuery, error) {
+
+	// /conversations?limit=20&offset=0
+	qs := req.URL.Query()
+
+	ints := map[string]*int{
+		"limit":  &ConversationQuery.Limit,
+		"offset": &ConversationQuery.Offset,
+	}
+	for key, dest := range ints {
+		if v := qs.Get(key); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return ConversationQuery, err
+			}
+			*dest = i
+		}
+	}
+
+	return ConversationQuery, nil
+}
+
+// MessageQuery pages backwards through a conversation, Before is the id of the oldest message the client has
+type MessageQuery struct {
+	Limit  int   `json:"limit" validate:"gte=1,lte=100"`
+	Before int64 `json:"before" validate:"gte=0"`
+}
+
+func (MessageQuery MessageQuery) Parse(req *http.Request) (MessageQuery, error) {
+
+	// /conversations/1/messages?limit=50&before=1234
+	qs := req.URL.Query()
+
+	if v := qs.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil {
+			return MessageQuery, err
+		}
+		MessageQuery.Limit = l
+	}
+
+	if v := qs.Get("before"); v != "" {
+		b, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return MessageQuery, err
+		}
+		MessageQuery.Before = b
+	}
+
+	return MessageQuery, nil
+}
+
+type DeliveryQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Status string `json:"status" validate:"omitempty,oneof=pending succeeded failed"`
+}
+
+func (DeliveryQuery DeliveryQuery) Parse(req *http.Request) (DeliveryQuery, error) {
+
+	// /webhooks/1/deliveries?limit=50&offset=0&status=failed
+	qs := req.URL.Query()
+
+	ints := map[string]*int{
+		"limit":  &DeliveryQuery.Limit,
+		"offset": &DeliveryQuery.Offset,
+	}
+	for key, dest := range ints {
+		if v := qs.Get(key); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return DeliveryQuery, err
+			}
+			*dest = i
+		}
+	}
+
+	DeliveryQuery.Status = qs.Get("status")
+
+	return DeliveryQuery, nil
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+
+	"github.com/lib/pq"
+)
+
+type IPermissionStore interface {
+	GetAll(context.Context) ([]types.Permission, error)
+	GetRolePermissions(context.Context) (map[int64][]string, error)
+	Grant(context.Context, int64, string) error
+	Revoke(context.Context, int64, string) error
+}
+
+type PermissionStore struct {
+	db *sql.DB
+}
+
+func (store *PermissionStore) GetAll(ctx context.Context) ([]types.Permission, error) {
+
+	query := `SELECT id,name,description FROM permissions ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []types.Permission{}
+
+	for rows.Next() {
+		var p types.Permission
+
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, p)
+	}
+
+	return permissions, nil
+}
+
+// GetRolePermissions returns the permission names granted to every role, keyed by role id
+func (store *PermissionStore) GetRolePermissions(ctx context.Context) (map[int64][]string, error) {
+
+	query := `
+	SELECT r.id, COALESCE(ARRAY_AGG(p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	LEFT JOIN permissions p ON p.id = rp.permission_id
+	GROUP BY r.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rolePermissions := map[int64][]string{}
+
+	for rows.Next() {
+		var roleId int64
+		var permissions []string
+
+		if err := rows.Scan(&roleId, pq.Array(&permissions)); err != nil {
+			return nil, err
+		}
+
+		rolePermissions[roleId] = permissions
+	}
+
+	return rolePermissions, nil
+}
+
+func (store *PermissionStore) Grant(ctx context.Context, roleId int64, permission string) error {
+
+	query := `
+	INSERT INTO role_permissions (role_id,permission_id)
+	SELECT $1, id FROM permissions WHERE name = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, roleId, permission)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505":
+				return ErrConflict
+			case "23503":
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (store *PermissionStore) Revoke(ctx context.Context, roleId int64, permission string) error {
+
+	query := `
+	DELETE FROM role_permissions
+	WHERE role_id = $1 AND permission_id = (SELECT id FROM permissions WHERE name = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, roleId, permission)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"social/internal/types"
+)
+
+type IPostRevisionStore interface {
+	GetByPostId(ctx context.Context, postId int64) ([]types.PostRevision, error)
+	GetByVersion(ctx context.Context, postId int64, version int) (*types.PostRevision, error)
+}
+
+// PostRevisionStore reads the revisions written by PostStore.Update,
+// each one holds a post as it was before an edit
+type PostRevisionStore struct {
+	db *sql.DB
+}
+
+func (store *PostRevisionStore) GetByPostId(ctx context.Context, postId int64) ([]types.PostRevision, error) {
+
+	query := `
+	SELECT id,post_id,version,title,content,visibility,created_at
+	FROM post_revisions
+	WHERE post_id = $1
+	ORDER BY version DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []types.PostRevision{}
+
+	for rows.Next() {
+		var revision types.PostRevision
+
+		err := rows.Scan(
+			&revision.ID,
+			&revision.PostId,
+			&revision.Version,
+			&revision.Title,
+			&revision.Content,
+			&revision.Visibility,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (store *PostRevisionStore) GetByVersion(ctx context.Context, postId int64, version int) (*types.PostRevision, error) {
+
+	query := `
+	SELECT id,post_id,version,title,content,visibility,created_at
+	FROM post_revisions
+	WHERE post_id = $1 AND version = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revision types.PostRevision
+
+	err := store.db.QueryRowContext(ctx, query, postId, version).Scan(
+		&revision.ID,
+		&revision.PostId,
+		&revision.Version,
+		&revision.Title,
+		&revision.Content,
+		&revision.Visibility,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
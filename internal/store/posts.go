@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"time"
 
 	"social/internal/types"
 
@@ -15,8 +15,19 @@ type IPostStore interface {
 	Create(ctx context.Context, post *types.Post) error
 	GetPostById(ctx context.Context, postId int64) (*types.Post, error)
 	Update(ctx context.Context, post *types.Post) error
-	Delete(ctx context.Context, postId int64) error
-	GetUserFeed(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error)
+	Delete(ctx context.Context, postId int64, version *int, deletedBy int64) error
+	GetDeletedById(ctx context.Context, postId int64) (*types.Post, error)
+	Restore(ctx context.Context, postId int64, deletedSince time.Time) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	SetHidden(ctx context.Context, postId int64, hidden bool) error
+	GetByStatus(ctx context.Context, userId int64, status string) ([]types.Post, error)
+	Schedule(ctx context.Context, postId int64, publishAt *time.Time) error
+	Publish(ctx context.Context, postId int64) error
+	PublishDue(ctx context.Context) ([]int64, error)
+	Repost(ctx context.Context, postId int64, userId int64) error
+	Unrepost(ctx context.Context, postId int64, userId int64) error
+	Pin(ctx context.Context, postId int64, userId int64, limit int) error
+	Unpin(ctx context.Context, postId int64, userId int64) error
 }
 
 type PostStore struct {
@@ -26,44 +37,84 @@ type PostStore struct {
 func (store *PostStore) Create(ctx context.Context, post *types.Post) error {
 
 	query := `
-	INSERT INTO posts (content,title,user_id,tags) 
-	VALUES($1,$2,$3,$4) RETURNING id,created_at,updated_at,version
+	INSERT INTO posts (content,title,user_id,tags,visibility,status,publish_at,quote_post_id,explicit_tags) 
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id,created_at,updated_at,version
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	if post.Visibility == "" {
+		post.Visibility = types.VisibilityPublic
+	}
 
-	err := store.db.QueryRowContext(ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserId,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-		&post.Version,
-	)
+	if post.Status == "" {
+		post.Status = types.PostPublished
+	}
+
+	explicitTags := post.ExplicitTags
+	if explicitTags == nil {
+		explicitTags = []string{}
+	}
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserId,
+			pq.Array(post.Tags),
+			post.Visibility,
+			post.Status,
+			post.PublishAt,
+			post.QuotePostId,
+			pq.Array(explicitTags),
+		).Scan(
+			&post.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		return store.syncMentions(ctx, tx, post)
+	})
+}
 
+// syncMentions replaces the mentions of the post with the users mentioned in its content
+func (store *PostStore) syncMentions(ctx context.Context, tx *sql.Tx, post *types.Post) error {
+
+	userIds, usernames, err := syncMentions(ctx, tx, "post_mentions", "post_id", post.ID, post.UserId, post.Content)
 	if err != nil {
 		return err
 	}
 
+	post.MentionedUserIds = userIds
+	post.Entities = mentionEntities(post.Content, userIds, usernames)
+
 	return nil
 }
 
 func (store *PostStore) GetPostById(ctx context.Context, postId int64) (*types.Post, error) {
 
 	query := `
-	SELECT id,user_id,title,content,created_at,updated_at,tags,version
-	FROM posts
-	WHERE id = $1
+	SELECT p.id,p.user_id,p.title,p.content,p.created_at,p.updated_at,p.tags,p.version,p.hidden_at,p.visibility,
+	p.status,p.publish_at,p.edited_at,p.quote_post_id,p.explicit_tags,
+	u.id,u.username,u.is_private,
+	` + mentionsSQL("post_mentions", "post_id", "p.id") + `
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = $1 AND p.deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 	var post types.Post
+	var usernames []string
 
 	err := store.db.QueryRowContext(ctx, query, postId).Scan(
 		&post.ID,
@@ -74,6 +125,18 @@ func (store *PostStore) GetPostById(ctx context.Context, postId int64) (*types.P
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
+		&post.HiddenAt,
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
+		&post.EditedAt,
+		&post.QuotePostId,
+		pq.Array(&post.ExplicitTags),
+		&post.User.ID,
+		&post.User.Username,
+		&post.User.IsPrivate,
+		pq.Array(&post.MentionedUserIds),
+		pq.Array(&usernames),
 	)
 
 	if err != nil {
@@ -85,50 +148,119 @@ func (store *PostStore) GetPostById(ctx context.Context, postId int64) (*types.P
 		}
 	}
 
+	post.Edited = post.EditedAt != nil
+	post.Entities = mentionEntities(post.Content, post.MentionedUserIds, usernames)
+
 	return &post, nil
 }
 
 func (store *PostStore) Update(ctx context.Context, post *types.Post) error {
 
+	// the post is kept as it was before the edit
+	revisionQuery := `
+	INSERT INTO post_revisions (post_id,version,title,content,visibility)
+	SELECT id,version,title,content,visibility FROM posts
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	`
+
 	query := `
 	UPDATE posts
-	SET content = $2,title = $3,version = version + 1
-	WHERE id = $1 AND version = $4
-	RETURNING version
+	SET content = $2,title = $3,visibility = $5,tags = $6,version = version + 1,edited_at = NOW(),updated_at = NOW()
+	WHERE id = $1 AND version = $4 AND deleted_at IS NULL
+	RETURNING version,edited_at,updated_at
+	`
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, revisionQuery, post.ID, post.Version); err != nil {
+			return err
+		}
+
+		err := tx.QueryRowContext(ctx,
+			query,
+			post.ID,
+			post.Content,
+			post.Title,
+			post.Version,
+			post.Visibility,
+			pq.Array(post.Tags),
+		).Scan(&post.Version, &post.EditedAt, &post.UpdatedAt)
+		if err != nil {
+			switch {
+			// the post was edited since it was read
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrVersionMismatch
+			default:
+				return err
+
+			}
+		}
+
+		post.Edited = true
+
+		return store.syncMentions(ctx, tx, post)
+	})
+}
+
+// Delete marks the post as deleted and unpins it, it stays restorable until PurgeDeleted removes it.
+// with a version the post is only deleted if it was not edited since, ErrVersionMismatch otherwise
+func (store *PostStore) Delete(ctx context.Context, postId int64, version *int, deletedBy int64) error {
+
+	query := `
+	UPDATE posts SET deleted_at = NOW(), deleted_by = $2, pinned_at = NULL
+	WHERE id = $1 AND deleted_at IS NULL AND ($3::int IS NULL OR version = $3)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := store.db.QueryRowContext(ctx,
-		query,
-		post.ID,
-		post.Content,
-		post.Title,
-		post.Version,
-	).Scan(&post.Version)
+
+	response, err := store.db.ExecContext(ctx, query, postId, deletedBy, version)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
+		return err
+	}
 
-		}
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if rows > 0 {
+		return nil
+	}
+
+	if version == nil {
+		return ErrNotFound
+	}
+
+	var exists bool
+
+	query = `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`
+
+	if err := store.db.QueryRowContext(ctx, query, postId).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrVersionMismatch
+	}
+
+	return ErrNotFound
 }
 
-func (store *PostStore) Delete(ctx context.Context, postId int64) error {
+func (store *PostStore) SetHidden(ctx context.Context, postId int64, hidden bool) error {
 
 	query := `
-	DELETE FROM posts WHERE id=$1
+	UPDATE posts SET hidden_at = CASE WHEN $2 THEN NOW() ELSE NULL END
+	WHERE id = $1
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	response, err := store.db.ExecContext(ctx, query, postId)
+	response, err := store.db.ExecContext(ctx, query, postId, hidden)
 	if err != nil {
 		return err
 	}
@@ -145,61 +277,344 @@ func (store *PostStore) Delete(ctx context.Context, postId int64) error {
 	return nil
 }
 
-func (store *PostStore) GetUserFeed(ctx context.Context, userId int64, PaginatedFeedQuery PaginatedFeedQuery) ([]types.PostWithMetadata, error) {
-
-	log.Printf("come from req limit: %v, offset: %v, sort: %v,search: %v", PaginatedFeedQuery.Limit, PaginatedFeedQuery.Offset, PaginatedFeedQuery.Sort, PaginatedFeedQuery.Search)
+// GetByStatus returns the drafts or scheduled posts of an author
+func (store *PostStore) GetByStatus(ctx context.Context, userId int64, status string) ([]types.Post, error) {
 
 	query := `
-	SELECT p.id,p.user_id,u.username,p.title,p.content,p.created_at,p.version,p.tags,
-	COUNT(c.id) AS comments_count
-	FROM posts p
-	LEFT JOIN comments c ON c.post_id = p.id
-	LEFT JOIN users u ON p.user_id = u.id
-	JOIN followers f ON f.user_id = p.user_id 
-	WHERE 
-		f.follower_id = $1 AND 
-		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
-	GROUP BY p.id, u.username
-	ORDER BY p.created_at ` + PaginatedFeedQuery.Sort + `
-	LIMIT $2 OFFSET $3
+	SELECT id,user_id,title,content,created_at,updated_at,tags,version,visibility,status,publish_at
+	FROM posts
+	WHERE user_id = $1 AND status = $2 AND deleted_at IS NULL
+	ORDER BY COALESCE(publish_at, updated_at) ASC
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := store.db.QueryContext(ctx, query,
-		userId,
-		PaginatedFeedQuery.Limit,
-		PaginatedFeedQuery.Offset,
-		PaginatedFeedQuery.Search)
+	rows, err := store.db.QueryContext(ctx, query, userId, status)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []types.PostWithMetadata
+	posts := []types.Post{}
 
 	for rows.Next() {
-		var p types.PostWithMetadata
+		var p types.Post
 
 		err := rows.Scan(
 			&p.ID,
 			&p.UserId,
-			&p.User.Username,
 			&p.Title,
 			&p.Content,
 			&p.CreatedAt,
-			&p.Version,
+			&p.UpdatedAt,
 			pq.Array(&p.Tags),
-			&p.CommentsCount,
+			&p.Version,
+			&p.Visibility,
+			&p.Status,
+			&p.PublishAt,
 		)
 		if err != nil {
 			return nil, err
 		}
-		feed = append(feed, p)
 
+		posts = append(posts, p)
 	}
 
-	return feed, nil
+	return posts, nil
+}
+
+// Schedule sets the publication time of an unpublished post, a nil time turns it back into a draft
+func (store *PostStore) Schedule(ctx context.Context, postId int64, publishAt *time.Time) error {
+
+	query := `
+	UPDATE posts
+	SET publish_at = $2, status = CASE WHEN $2::timestamptz IS NULL THEN 'draft' ELSE 'scheduled' END
+	WHERE id = $1 AND status <> 'published' AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, postId, publishAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Publish makes an unpublished post visible, the post is dated from its publication
+func (store *PostStore) Publish(ctx context.Context, postId int64) error {
+
+	query := `
+	UPDATE posts
+	SET status = 'published', publish_at = NULL, created_at = NOW()
+	WHERE id = $1 AND status <> 'published' AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, postId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PublishDue publishes the scheduled posts whose time has come and returns their ids
+func (store *PostStore) PublishDue(ctx context.Context) ([]int64, error) {
+
+	query := `
+	UPDATE posts
+	SET status = 'published', created_at = publish_at, publish_at = NULL
+	WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+	RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetDeletedById returns a post deleted but not yet purged
+func (store *PostStore) GetDeletedById(ctx context.Context, postId int64) (*types.Post, error) {
 
+	query := `
+	SELECT id,user_id,title,content,created_at,updated_at,tags,version,visibility,status,deleted_at,deleted_by
+	FROM posts
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var post types.Post
+
+	err := store.db.QueryRowContext(ctx, query, postId).Scan(
+		&post.ID,
+		&post.UserId,
+		&post.Title,
+		&post.Content,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		pq.Array(&post.Tags),
+		&post.Version,
+		&post.Visibility,
+		&post.Status,
+		&post.DeletedAt,
+		&post.DeletedBy,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &post, nil
+}
+
+// Restore brings back a post deleted after deletedSince
+func (store *PostStore) Restore(ctx context.Context, postId int64, deletedSince time.Time) error {
+
+	query := `
+	UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+	WHERE id = $1 AND deleted_at >= $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, postId, deletedSince)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted removes the posts deleted before the given time, their comments go with them
+func (store *PostStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+
+	query := `DELETE FROM posts WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.RowsAffected()
+}
+
+func (store *PostStore) Repost(ctx context.Context, postId int64, userId int64) error {
+
+	query := `INSERT INTO reposts (user_id,post_id) VALUES ($1,$2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, userId, postId)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (store *PostStore) Unrepost(ctx context.Context, postId int64, userId int64) error {
+
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, userId, postId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Pin pins a post of the user, ErrPinLimit when the user already has limit pinned posts
+// and ErrConflict when the post is pinned already. the user row is locked so concurrent
+// pins can not go over the limit
+func (store *PostStore) Pin(ctx context.Context, postId int64, userId int64, limit int) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `SELECT id FROM users WHERE id = $1 FOR UPDATE`
+
+		if err := tx.QueryRowContext(ctx, query, userId).Scan(&userId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var pinned int
+
+		// only the pins shown on the profile count
+		query = `
+		SELECT COUNT(*) FROM posts
+		WHERE user_id = $1 AND pinned_at IS NOT NULL AND deleted_at IS NULL AND hidden_at IS NULL AND status = 'published'
+		`
+
+		if err := tx.QueryRowContext(ctx, query, userId).Scan(&pinned); err != nil {
+			return err
+		}
+
+		if pinned >= limit {
+			return ErrPinLimit
+		}
+
+		query = `UPDATE posts SET pinned_at = NOW() WHERE id = $1 AND user_id = $2 AND pinned_at IS NULL AND deleted_at IS NULL`
+
+		response, err := tx.ExecContext(ctx, query, postId, userId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := response.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrConflict
+		}
+
+		return nil
+	})
+}
+
+func (store *PostStore) Unpin(ctx context.Context, postId int64, userId int64) error {
+
+	query := `UPDATE posts SET pinned_at = NULL WHERE id = $1 AND user_id = $2 AND pinned_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, postId, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
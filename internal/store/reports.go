@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+
+	"github.com/lib/pq"
+)
+
+type IReportStore interface {
+	Create(context.Context, *types.Report) error
+	GetAll(context.Context, ReportQuery) ([]types.Report, error)
+	GetById(context.Context, int64) (*types.Report, error)
+	Resolve(ctx context.Context, report *types.Report, apply func(ctx context.Context) error) error
+}
+
+type ReportStore struct {
+	db *sql.DB
+}
+
+// the author of the reported content, 0 once the content is purged. the target counts as deleted
+// from its soft delete on, a comment also when its post is deleted
+const reportColumns = `
+	r.id,r.reporter_id,r.target_type,r.target_id,COALESCE(p.user_id, c.user_id, 0),
+	CASE WHEN r.target_type = 'post'
+		THEN p.id IS NULL OR p.deleted_at IS NOT NULL
+		ELSE c.id IS NULL OR c.deleted_at IS NOT NULL OR cp.deleted_at IS NOT NULL
+	END,
+	r.reason,r.details,r.status,r.resolution,r.note,r.resolved_by,r.resolved_at,r.created_at
+	FROM reports r
+	LEFT JOIN posts p ON r.target_type = 'post' AND p.id = r.target_id
+	LEFT JOIN comments c ON r.target_type = 'comment' AND c.id = r.target_id
+	LEFT JOIN posts cp ON cp.id = c.post_id
+`
+
+func (store *ReportStore) Create(ctx context.Context, report *types.Report) error {
+
+	query := `
+	INSERT INTO reports (reporter_id,target_type,target_id,reason,details)
+	VALUES ($1,$2,$3,$4,$5)
+	RETURNING id,status,created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := store.db.QueryRowContext(ctx,
+		query,
+		report.ReporterId,
+		report.TargetType,
+		report.TargetId,
+		report.Reason,
+		report.Details,
+	).Scan(
+		&report.ID,
+		&report.Status,
+		&report.CreatedAt,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (store *ReportStore) GetAll(ctx context.Context, fq ReportQuery) ([]types.Report, error) {
+
+	query := `SELECT` + reportColumns + `
+	WHERE
+		r.status = $1 AND
+		($2 = '' OR r.target_type = $2) AND
+		($3 = '' OR r.reason = $3)
+	ORDER BY r.created_at ASC
+	LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, fq.Status, fq.TargetType, fq.Reason, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []types.Report{}
+
+	for rows.Next() {
+		var r types.Report
+
+		if err := scanReport(rows, &r); err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, r)
+	}
+
+	return reports, nil
+}
+
+func (store *ReportStore) GetById(ctx context.Context, reportId int64) (*types.Report, error) {
+
+	query := `SELECT` + reportColumns + `WHERE r.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	report := &types.Report{}
+
+	if err := scanReport(store.db.QueryRowContext(ctx, query, reportId), report); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// Resolve closes every open report on the same content with the resolution of this one and runs apply,
+// the moderation action, while they are claimed. ErrConflict when they were resolved already, they are
+// kept open when apply fails. concurrent resolutions wait for the claim and find them resolved
+func (store *ReportStore) Resolve(ctx context.Context, report *types.Report, apply func(ctx context.Context) error) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		query := `
+		UPDATE reports
+		SET status = $1, resolution = $2, note = $3, resolved_by = $4, resolved_at = NOW()
+		WHERE target_type = $5 AND target_id = $6 AND status = 'open'
+		`
+
+		claimCtx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		response, err := tx.ExecContext(claimCtx,
+			query,
+			report.Status,
+			report.Resolution,
+			report.Note,
+			report.ResolvedBy,
+			report.TargetType,
+			report.TargetId,
+		)
+		if err != nil {
+			return err
+		}
+
+		rows, err := response.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrConflict
+		}
+
+		return apply(ctx)
+	})
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReport(row scanner, r *types.Report) error {
+	return row.Scan(
+		&r.ID,
+		&r.ReporterId,
+		&r.TargetType,
+		&r.TargetId,
+		&r.TargetUserId,
+		&r.TargetDeleted,
+		&r.Reason,
+		&r.Details,
+		&r.Status,
+		&r.Resolution,
+		&r.Note,
+		&r.ResolvedBy,
+		&r.ResolvedAt,
+		&r.CreatedAt,
+	)
+}
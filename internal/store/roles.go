@@ -8,6 +8,7 @@ import (
 
 type IRoleStore interface {
 	GetByName(context.Context, string) (*types.Role, error)
+	GetAll(context.Context) ([]types.Role, error)
 }
 
 type RoleStore struct {
@@ -42,3 +43,31 @@ func (store *RoleStore) GetByName(ctx context.Context, rolename string) (*types.
 	return role, nil
 
 }
+
+func (store *RoleStore) GetAll(ctx context.Context) ([]types.Role, error) {
+
+	query := `SELECT id,name,level,description FROM roles ORDER BY level`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []types.Role{}
+
+	for rows.Next() {
+		var role types.Role
+
+		if err := rows.Scan(&role.ID, &role.Name, &role.Level, &role.Description); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
@@ -10,17 +10,32 @@ import (
 var (
 	ErrNotFound          = errors.New("record not found")
 	ErrConflict          = errors.New("resource already exists")
+	ErrVersionMismatch   = errors.New("resource was modified")
 	ErrDuplicateUsername = errors.New("username already exists")
 	ErrDuplicateEmail    = errors.New("email already exists")
+	ErrPinLimit          = errors.New("pinned posts limit reached")
 	ErrWDFUQ             = errors.New("what the fck is goin on")
 	QueryTimeoutDuration = time.Second * 5
 )
 
 type Storage struct {
-	Posts    IPostStore
-	Users    IUserStore
-	Comments ICommentStore
-	Roles    IRoleStore
+	Posts         IPostStore
+	Users         IUserStore
+	Comments      ICommentStore
+	Roles         IRoleStore
+	ApiKeys       IApiKeyStore
+	Permissions   IPermissionStore
+	AuditLogs     IAuditLogStore
+	Reports       IReportStore
+	Revisions     IPostRevisionStore
+	Bookmarks     IBookmarkStore
+	Tags          ITagStore
+	Mentions      IMentionStore
+	Notifications INotificationStore
+	Conversations IConversationStore
+	Webhooks      IWebhookStore
+	Timelines     ITimelineStore
+	Explore       IExploreStore
 }
 
 func NewStorage(db *sql.DB) *Storage {
@@ -37,6 +52,45 @@ func NewStorage(db *sql.DB) *Storage {
 		Roles: &RoleStore{
 			db: db,
 		},
+		ApiKeys: &ApiKeyStore{
+			db: db,
+		},
+		Permissions: &PermissionStore{
+			db: db,
+		},
+		AuditLogs: &AuditLogStore{
+			db: db,
+		},
+		Reports: &ReportStore{
+			db: db,
+		},
+		Revisions: &PostRevisionStore{
+			db: db,
+		},
+		Bookmarks: &BookmarkStore{
+			db: db,
+		},
+		Tags: &TagStore{
+			db: db,
+		},
+		Mentions: &MentionStore{
+			db: db,
+		},
+		Notifications: &NotificationStore{
+			db: db,
+		},
+		Conversations: &ConversationStore{
+			db: db,
+		},
+		Webhooks: &WebhookStore{
+			db: db,
+		},
+		Timelines: &TimelineStore{
+			db: db,
+		},
+		Explore: &ExploreStore{
+			db: db,
+		},
 	}
 }
 
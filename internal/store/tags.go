@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ITagStore interface {
+	GetPosts(ctx context.Context, viewer Viewer, tag string, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error)
+	GetTrending(ctx context.Context, window time.Duration, halfLife time.Duration, limit int) ([]types.TrendingTag, error)
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// GetPosts pages through the posts with a tag that the viewer can see
+func (store *TagStore) GetPosts(ctx context.Context, viewer Viewer, tag string, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error) {
+
+	query := `
+	SELECT p.id,p.user_id,u.username,p.title,p.content,p.created_at,p.version,p.tags,p.visibility,p.edited_at,p.quote_post_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id) AS reposts_count,
+	EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $1) AS bookmarked,
+	` + mentionsSQL("post_mentions", "post_id", "p.id") + `
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		p.tags @> ARRAY[$6]::varchar(100)[] AND
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
+		` + visiblePostSQL("$1", "$5", "p") + ` AND
+		` + notMutedSQL("$1", "p.user_id") + `
+	ORDER BY p.created_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator,
+		tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		var p types.PostWithMetadata
+		var usernames []string
+
+		err := rows.Scan(
+			&p.ID,
+			&p.UserId,
+			&p.User.Username,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.EditedAt,
+			&p.QuotePostId,
+			&p.CommentsCount,
+			&p.RepostsCount,
+			&p.Bookmarked,
+			pq.Array(&p.MentionedUserIds),
+			pq.Array(&usernames),
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Edited = p.EditedAt != nil
+		p.Entities = mentionEntities(p.Content, p.MentionedUserIds, usernames)
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetTrending ranks the tags used in the window, every post counts for a weight
+// that halves each halfLife so recent use outweighs older use.
+// only public posts of public accounts are counted, the result is the same for everyone
+func (store *TagStore) GetTrending(ctx context.Context, window time.Duration, halfLife time.Duration, limit int) ([]types.TrendingTag, error) {
+
+	query := `
+	SELECT t.tag,
+	SUM(POWER(0.5, EXTRACT(EPOCH FROM NOW() - p.created_at) / $2)) AS score,
+	COUNT(*) AS posts_count
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+	WHERE
+		p.created_at > NOW() - make_interval(secs => $1) AND
+		p.status = 'published' AND
+		p.visibility = 'public' AND
+		p.hidden_at IS NULL AND
+		p.deleted_at IS NULL AND
+		NOT u.is_private
+	GROUP BY t.tag
+	ORDER BY score DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, window.Seconds(), halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []types.TrendingTag{}
+
+	for rows.Next() {
+		var tag types.TrendingTag
+		if err := rows.Scan(&tag.Tag, &tag.Score, &tag.PostsCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
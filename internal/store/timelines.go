@@ -0,0 +1,659 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/types"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ITimelineStore interface {
+	Get(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error)
+	GetRanked(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery, ranking Ranking) ([]types.PostWithMetadata, error)
+	GetProfile(ctx context.Context, viewer Viewer, userId int64, fq PaginatedFeedQuery, include ProfileInclude) ([]types.PostWithMetadata, error)
+	AddPost(ctx context.Context, postId int64, fanoutThreshold int) (int64, error)
+	AddRepost(ctx context.Context, userId int64, postId int64, fanoutThreshold int) (int64, error)
+	RemoveRepost(ctx context.Context, userId int64, postId int64) error
+	Backfill(ctx context.Context, followerId int64, userId int64, limit int) error
+	Prune(ctx context.Context, followerId int64, userId int64) error
+	Enqueue(ctx context.Context, job TimelineJob) error
+	Drain(ctx context.Context, limit int, retry JobRetry, run func(ctx context.Context, job TimelineJob) error) (int, error)
+}
+
+// TimelineStore keeps the home timelines, the posts and reposts of the followed users are written
+// into the timeline of every follower. the ones made by users with fanoutThreshold followers or more
+// are flagged read_merged instead and merged into the timelines of the followers at read time, the
+// flag stays so they keep showing up if the follower count of their author changes afterwards
+type TimelineStore struct {
+	db *sql.DB
+}
+
+// TimelineJob is a pending timeline write, UserId is the author of the post, the user who
+// reposted it or the followed user depending on the kind
+type TimelineJob struct {
+	ID         int64
+	Kind       string
+	UserId     int64
+	PostId     int64
+	FollowerId int64
+
+	// failed attempts so far, the next one is not made before NextAttemptAt
+	Attempts      int
+	NextAttemptAt time.Time
+}
+
+// JobRetry is how failed jobs are retried, the wait grows by Backoff with every attempt
+// and a job is set aside as failed after MaxAttempts
+type JobRetry struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+const (
+	TimelinePost     = "post"
+	TimelineRepost   = "repost"
+	TimelineUnrepost = "unrepost"
+	TimelineFollow   = "follow"
+	TimelineUnfollow = "unfollow"
+)
+
+// timelineSQL is the feed CTE of the viewer $1, its timeline items merged with the read-merged
+// posts and reposts of the followed users, one row per post
+var timelineSQL = `
+	items AS (
+		SELECT t.post_id, t.activity_at, CASE WHEN t.reposted THEN t.source_id END AS reposter_id
+		FROM timeline_items t
+		WHERE t.user_id = $1
+		UNION ALL
+		SELECT p.id, p.created_at, NULL::bigint
+		FROM followers f
+		JOIN posts p ON p.user_id = f.user_id AND p.read_merged
+		WHERE f.follower_id = $1
+		UNION ALL
+		SELECT r.post_id, r.created_at, r.user_id
+		FROM followers f
+		JOIN reposts r ON r.user_id = f.user_id AND r.read_merged
+		WHERE f.follower_id = $1
+	), feed AS (
+		SELECT post_id, MAX(activity_at) AS activity_at,
+		ARRAY_AGG(DISTINCT reposter_id) FILTER (WHERE reposter_id IS NOT NULL) AS reposter_ids
+		FROM items
+		WHERE reposter_id IS NULL OR ` + notMutedSQL("$1", "reposter_id") + `
+		GROUP BY post_id
+	)`
+
+// feedColumnsSQL are the columns read by scanFeedPost, feed is the alias of the timeline row
+var feedColumnsSQL = `
+	p.id,p.user_id,u.username,p.title,p.content,p.created_at,p.version,p.tags,p.visibility,p.edited_at,p.quote_post_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id) AS reposts_count,
+	EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $1) AS bookmarked,
+	` + mentionsSQL("post_mentions", "post_id", "p.id") + `,
+	ARRAY(
+		SELECT ru.username FROM reposts rr
+		JOIN users ru ON ru.id = rr.user_id
+		WHERE rr.post_id = p.id AND rr.user_id = ANY(feed.reposter_ids)
+		ORDER BY rr.created_at DESC
+	) AS reposted_by`
+
+// feedFilterSQL keeps the posts the viewer can see, not muted and matching the search $4
+var feedFilterSQL = `
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
+		` + visiblePostSQL("$1", "$5", "p") + ` AND
+		` + notMutedSQL("$1", "p.user_id")
+
+func scanFeedPost(rows *sql.Rows, extra ...any) (types.PostWithMetadata, error) {
+
+	var p types.PostWithMetadata
+	var usernames []string
+
+	err := rows.Scan(append([]any{
+		&p.ID,
+		&p.UserId,
+		&p.User.Username,
+		&p.Title,
+		&p.Content,
+		&p.CreatedAt,
+		&p.Version,
+		pq.Array(&p.Tags),
+		&p.Visibility,
+		&p.EditedAt,
+		&p.QuotePostId,
+		&p.CommentsCount,
+		&p.RepostsCount,
+		&p.Bookmarked,
+		pq.Array(&p.MentionedUserIds),
+		pq.Array(&usernames),
+		pq.Array(&p.RepostedBy),
+	}, extra...)...)
+	if err != nil {
+		return p, err
+	}
+
+	p.Edited = p.EditedAt != nil
+	p.Entities = mentionEntities(p.Content, p.MentionedUserIds, usernames)
+
+	return p, nil
+}
+
+// Get returns the timeline of the viewer in chronological order, a post reposted by several
+// followed users shows up once with all of them in RepostedBy
+func (store *TimelineStore) Get(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery) ([]types.PostWithMetadata, error) {
+
+	query := `
+	WITH ` + timelineSQL + `
+	SELECT ` + feedColumnsSQL + `
+	FROM feed
+	JOIN posts p ON p.id = feed.post_id
+	JOIN users u ON p.user_id = u.id
+	WHERE ` + feedFilterSQL + `
+	ORDER BY feed.activity_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		p, err := scanFeedPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		feed = append(feed, p)
+	}
+
+	return feed, rows.Err()
+}
+
+// ProfileInclude picks what a profile timeline shows besides the posts of the user,
+// replies are the comments of the user listed with the post they were made on
+type ProfileInclude struct {
+	Replies bool
+	Reposts bool
+}
+
+// GetProfile returns the posts of the user the viewer can see, quote posts included, its pinned posts first
+// and the rest in chronological order. a post reposted by the user is listed at the time of the repost,
+// a post commented by the user once for every comment at the time of the comment
+func (store *TimelineStore) GetProfile(ctx context.Context, viewer Viewer, userId int64, fq PaginatedFeedQuery, include ProfileInclude) ([]types.PostWithMetadata, error) {
+
+	pinned := `p.pinned_at IS NOT NULL AND p.user_id = $6 AND feed.comment_id IS NULL`
+
+	query := `
+	WITH items AS (
+		SELECT p.id AS post_id, p.created_at AS activity_at, NULL::bigint AS reposter_id, NULL::bigint AS comment_id
+		FROM posts p
+		WHERE p.user_id = $6
+		UNION ALL
+		SELECT r.post_id, r.created_at, r.user_id, NULL::bigint
+		FROM reposts r
+		WHERE r.user_id = $6 AND $7
+		UNION ALL
+		SELECT c.post_id, c.created_at, NULL::bigint, c.id
+		FROM comments c
+		WHERE c.user_id = $6 AND $8
+	), feed AS (
+		SELECT post_id, comment_id, MAX(activity_at) AS activity_at,
+		ARRAY_AGG(DISTINCT reposter_id) FILTER (WHERE reposter_id IS NOT NULL) AS reposter_ids
+		FROM items
+		GROUP BY post_id, comment_id
+	)
+	SELECT ` + feedColumnsSQL + `,
+	` + pinned + ` AS pinned,
+	rc.id, rc.content, rc.created_at, ru.username,
+	` + mentionsSQL("comment_mentions", "comment_id", "rc.id") + `
+	FROM feed
+	JOIN posts p ON p.id = feed.post_id
+	JOIN users u ON p.user_id = u.id
+	LEFT JOIN comments rc ON rc.id = feed.comment_id
+	LEFT JOIN users ru ON ru.id = rc.user_id
+	WHERE
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%' OR rc.content ILIKE '%' || $4 || '%') AND
+		` + visiblePostSQL("$1", "$5", "p") + ` AND
+		(feed.comment_id IS NULL OR (rc.deleted_at IS NULL AND (rc.hidden_at IS NULL OR $5)))
+	ORDER BY pinned DESC, CASE WHEN ` + pinned + ` THEN p.pinned_at END DESC NULLS LAST,
+	feed.activity_at ` + fq.Sort + `, p.id ` + fq.Sort + `, feed.comment_id ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator,
+		userId,
+		include.Reposts,
+		include.Replies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		var (
+			pinned          bool
+			replyId         sql.NullInt64
+			replyContent    sql.NullString
+			replyCreatedAt  sql.NullString
+			replyUsername   sql.NullString
+			replyMentionIds []int64
+			replyUsernames  []string
+		)
+
+		p, err := scanFeedPost(rows,
+			&pinned,
+			&replyId,
+			&replyContent,
+			&replyCreatedAt,
+			&replyUsername,
+			pq.Array(&replyMentionIds),
+			pq.Array(&replyUsernames),
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Pinned = pinned
+
+		if replyId.Valid {
+			p.Reply = &types.Comment{
+				ID:               replyId.Int64,
+				PostId:           p.ID,
+				UserId:           userId,
+				Content:          replyContent.String,
+				CreatedAt:        replyCreatedAt.String,
+				User:             types.User{ID: userId, Username: replyUsername.String},
+				MentionedUserIds: replyMentionIds,
+				Entities:         mentionEntities(replyContent.String, replyMentionIds, replyUsernames),
+			}
+		}
+
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// AddPost writes a published post into the timelines of the followers of its author and returns
+// how many were written, none when the author has fanoutThreshold followers or more and the post is read-merged
+func (store *TimelineStore) AddPost(ctx context.Context, postId int64, fanoutThreshold int) (int64, error) {
+
+	query := `
+	WITH p AS (
+		UPDATE posts SET read_merged = u.followers_count >= $2
+		FROM users u
+		WHERE posts.id = $1 AND u.id = posts.user_id AND posts.status = 'published' AND posts.deleted_at IS NULL
+		RETURNING posts.id, posts.user_id, posts.created_at, posts.read_merged
+	)
+	INSERT INTO timeline_items (user_id,post_id,source_id,activity_at)
+	SELECT f.follower_id, p.id, p.user_id, p.created_at
+	FROM p
+	JOIN followers f ON f.user_id = p.user_id
+	WHERE NOT p.read_merged
+	ON CONFLICT DO NOTHING
+	`
+
+	return store.insert(ctx, query, postId, fanoutThreshold)
+}
+
+// AddRepost writes a repost into the timelines of the followers of the user who reposted
+func (store *TimelineStore) AddRepost(ctx context.Context, userId int64, postId int64, fanoutThreshold int) (int64, error) {
+
+	query := `
+	WITH r AS (
+		UPDATE reposts SET read_merged = u.followers_count >= $3
+		FROM users u
+		WHERE reposts.user_id = $1 AND reposts.post_id = $2 AND u.id = reposts.user_id
+		RETURNING reposts.user_id, reposts.post_id, reposts.created_at, reposts.read_merged
+	)
+	INSERT INTO timeline_items (user_id,post_id,source_id,reposted,activity_at)
+	SELECT f.follower_id, r.post_id, r.user_id, true, r.created_at
+	FROM r
+	JOIN followers f ON f.user_id = r.user_id
+	WHERE NOT r.read_merged
+	ON CONFLICT DO NOTHING
+	`
+
+	return store.insert(ctx, query, userId, postId, fanoutThreshold)
+}
+
+func (store *TimelineStore) insert(ctx context.Context, query string, args ...any) (int64, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.RowsAffected()
+}
+
+func (store *TimelineStore) RemoveRepost(ctx context.Context, userId int64, postId int64) error {
+
+	query := `DELETE FROM timeline_items WHERE post_id = $2 AND source_id = $1 AND reposted`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, userId, postId)
+	return err
+}
+
+// Backfill writes the latest posts and reposts of a newly followed user into the timeline of the follower,
+// the read-merged ones show up without it
+func (store *TimelineStore) Backfill(ctx context.Context, followerId int64, userId int64, limit int) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO timeline_items (user_id,post_id,source_id,activity_at)
+		SELECT $1, p.id, p.user_id, p.created_at
+		FROM posts p
+		WHERE p.user_id = $2 AND p.status = 'published' AND p.deleted_at IS NULL AND NOT p.read_merged
+		ORDER BY p.created_at DESC
+		LIMIT $3
+		ON CONFLICT DO NOTHING
+		`
+
+		if _, err := tx.ExecContext(ctx, query, followerId, userId, limit); err != nil {
+			return err
+		}
+
+		query = `
+		INSERT INTO timeline_items (user_id,post_id,source_id,reposted,activity_at)
+		SELECT $1, r.post_id, r.user_id, true, r.created_at
+		FROM reposts r
+		WHERE r.user_id = $2 AND NOT r.read_merged
+		ORDER BY r.created_at DESC
+		LIMIT $3
+		ON CONFLICT DO NOTHING
+		`
+
+		_, err := tx.ExecContext(ctx, query, followerId, userId, limit)
+		return err
+	})
+}
+
+// Prune removes what came through an unfollowed user from the timeline of the former follower
+func (store *TimelineStore) Prune(ctx context.Context, followerId int64, userId int64) error {
+
+	query := `DELETE FROM timeline_items WHERE user_id = $1 AND source_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, followerId, userId)
+	return err
+}
+
+// Enqueue persists a timeline write for the worker draining the jobs
+func (store *TimelineStore) Enqueue(ctx context.Context, job TimelineJob) error {
+
+	query := `
+	INSERT INTO timeline_jobs (kind,user_id,post_id,follower_id)
+	VALUES ($1,$2,$3,$4)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := store.db.ExecContext(ctx, query, job.Kind, job.UserId, job.PostId, job.FollowerId)
+	return err
+}
+
+// Drain runs the oldest jobs in the order they were queued, up to limit, and returns how many were run.
+// a single instance drains at a time so the writes keep their order. succeeded jobs are removed, a failed
+// one is retried after a backoff and the jobs queued after it wait for it, unless it ran out of attempts
+// and is kept as failed. the jobs are run again if the drain does not complete
+func (store *TimelineStore) Drain(ctx context.Context, limit int, retry JobRetry, run func(ctx context.Context, job TimelineJob) error) (int, error) {
+
+	ran := 0
+
+	err := withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		jobs := []TimelineJob{}
+
+		if err := store.takeJobs(ctx, tx, limit, &jobs); err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		done := []int64{}
+
+		for _, job := range jobs {
+			if job.NextAttemptAt.After(time.Now()) {
+				break
+			}
+
+			ran++
+
+			err := run(ctx, job)
+			if err == nil {
+				done = append(done, job.ID)
+				continue
+			}
+
+			failed, err := store.recordJobFailure(ctx, tx, job, retry, err)
+			if err != nil {
+				return err
+			}
+			if !failed {
+				break
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM timeline_jobs WHERE id = ANY($1)`, pq.Array(done))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return ran, nil
+}
+
+// recordJobFailure schedules the next attempt of a failed job, failed tells whether it ran out of attempts
+func (store *TimelineStore) recordJobFailure(ctx context.Context, tx *sql.Tx, job TimelineJob, retry JobRetry, jobErr error) (bool, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	attempts := job.Attempts + 1
+	failed := attempts >= retry.MaxAttempts
+
+	query := `
+	UPDATE timeline_jobs SET
+		attempts = $2,
+		next_attempt_at = NOW() + make_interval(secs => $3),
+		failed_at = CASE WHEN $4 THEN NOW() END,
+		error = $5
+	WHERE id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, job.ID, attempts, (retry.Backoff * time.Duration(attempts)).Seconds(), failed, jobErr.Error())
+
+	return failed, err
+}
+
+// takeJobs reads the oldest jobs that did not fail for good, none when another instance is draining them
+func (store *TimelineStore) takeJobs(ctx context.Context, tx *sql.Tx, limit int, jobs *[]TimelineJob) error {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('timeline_jobs'))`).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	query := `
+	SELECT id,kind,user_id,post_id,follower_id,attempts,next_attempt_at
+	FROM timeline_jobs
+	WHERE failed_at IS NULL
+	ORDER BY id
+	LIMIT $1
+	`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var job TimelineJob
+		if err := rows.Scan(&job.ID, &job.Kind, &job.UserId, &job.PostId, &job.FollowerId, &job.Attempts, &job.NextAttemptAt); err != nil {
+			return err
+		}
+		*jobs = append(*jobs, job)
+	}
+
+	return rows.Err()
+}
+
+// Ranking are the weights of the signals of the ranked feed. recency halves every HalfLife,
+// candidates are the timeline posts of the Window and affinity and interests come from the
+// interactions of the viewer over the History
+type Ranking struct {
+	Recency     float64
+	Engagement  float64
+	Affinity    float64
+	TagInterest float64
+
+	HalfLife time.Duration
+	Window   time.Duration
+	History  time.Duration
+}
+
+// GetRanked returns the timeline of the viewer sorted by score:
+//   - recency, 1 for a post that just entered the timeline, halving every half life
+//   - engagement, ln(1 + comments + reposts)
+//   - affinity, ln(1 + interactions of the viewer with the author), its comments, reposts,
+//     bookmarks and mentions
+//   - tag interest, the share of the tags of the post the viewer interacted with
+func (store *TimelineStore) GetRanked(ctx context.Context, viewer Viewer, fq PaginatedFeedQuery, ranking Ranking) ([]types.PostWithMetadata, error) {
+
+	query := `
+	WITH ` + timelineSQL + `, interactions AS (
+		SELECT p.user_id AS author_id, p.tags
+		FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.user_id = $1 AND c.created_at > NOW() - make_interval(secs => $8)
+		UNION ALL
+		SELECT p.user_id, p.tags
+		FROM reposts r JOIN posts p ON p.id = r.post_id
+		WHERE r.user_id = $1 AND r.created_at > NOW() - make_interval(secs => $8)
+		UNION ALL
+		SELECT p.user_id, p.tags
+		FROM bookmarks b JOIN posts p ON p.id = b.post_id
+		WHERE b.user_id = $1 AND b.created_at > NOW() - make_interval(secs => $8)
+		UNION ALL
+		SELECT pm.user_id, p.tags
+		FROM posts p JOIN post_mentions pm ON pm.post_id = p.id
+		WHERE p.user_id = $1 AND p.created_at > NOW() - make_interval(secs => $8)
+	), affinity AS (
+		SELECT author_id, COUNT(*) AS interactions
+		FROM interactions
+		WHERE author_id <> $1
+		GROUP BY author_id
+	), interests AS (
+		SELECT DISTINCT t.tag
+		FROM (
+			SELECT tags FROM interactions
+			UNION ALL
+			SELECT tags FROM posts WHERE user_id = $1 AND created_at > NOW() - make_interval(secs => $8)
+		) i
+		CROSS JOIN LATERAL unnest(i.tags) AS t(tag)
+	), scored AS (
+		SELECT feed.post_id,
+		$9 * POWER(0.5, GREATEST(EXTRACT(EPOCH FROM NOW() - feed.activity_at), 0) / $7) AS recency,
+		$10 * LN(1 +
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) +
+			(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id)
+		) AS engagement,
+		$11 * LN(1 + COALESCE(a.interactions, 0)) AS affinity,
+		$12 * (SELECT COUNT(*) FROM unnest(p.tags) AS pt(tag) WHERE pt.tag IN (SELECT tag FROM interests))::float8
+			/ GREATEST(cardinality(p.tags), 1) AS tag_interest
+		FROM feed
+		JOIN posts p ON p.id = feed.post_id
+		LEFT JOIN affinity a ON a.author_id = p.user_id
+		WHERE feed.activity_at > NOW() - make_interval(secs => $6)
+	)
+	SELECT ` + feedColumnsSQL + `,
+	s.recency, s.engagement, s.affinity, s.tag_interest
+	FROM scored s
+	JOIN feed ON feed.post_id = s.post_id
+	JOIN posts p ON p.id = s.post_id
+	JOIN users u ON p.user_id = u.id
+	WHERE ` + feedFilterSQL + `
+	ORDER BY s.recency + s.engagement + s.affinity + s.tag_interest DESC, p.id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query,
+		viewer.ID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		viewer.Moderator,
+		ranking.Window.Seconds(),
+		ranking.HalfLife.Seconds(),
+		ranking.History.Seconds(),
+		ranking.Recency,
+		ranking.Engagement,
+		ranking.Affinity,
+		ranking.TagInterest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []types.PostWithMetadata{}
+
+	for rows.Next() {
+		var score types.FeedScore
+
+		p, err := scanFeedPost(rows, &score.Recency, &score.Engagement, &score.Affinity, &score.TagInterest)
+		if err != nil {
+			return nil, err
+		}
+
+		score.Total = score.Recency + score.Engagement + score.Affinity + score.TagInterest
+		p.Score = &score
+		feed = append(feed, p)
+	}
+
+	return feed, rows.Err()
+}
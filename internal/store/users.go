@@ -22,6 +22,26 @@ type IUserStore interface {
 	RegisterUser(context.Context, *types.RegisterUserPayload) error
 	CreateAndInvite(context.Context, *types.User, string, time.Duration) error
 	Activate(context.Context, string) error
+	GetAll(context.Context, UserFilterQuery) ([]types.User, error)
+	GetAnyById(context.Context, int64) (*types.User, error)
+	SetRole(context.Context, int64, string) error
+	SetActive(context.Context, int64, bool) error
+	RevokeTokens(context.Context, int64) error
+	Warn(context.Context, int64) error
+	Suspend(context.Context, int64, time.Time) error
+	GetRelationship(context.Context, int64, int64) (Relationship, error)
+	Block(context.Context, int64, int64) error
+	Unblock(context.Context, int64, int64) error
+	Mute(context.Context, int64, int64) error
+	Unmute(context.Context, int64, int64) error
+	GetBlocked(context.Context, int64) ([]types.User, error)
+	GetMuted(context.Context, int64) ([]types.User, error)
+	GetFollowing(context.Context, int64) ([]types.User, error)
+	SetPrivate(context.Context, int64, bool) ([]int64, error)
+	RequestFollow(context.Context, int64, int64) error
+	CancelFollowRequest(context.Context, int64, int64) error
+	GetFollowRequests(context.Context, int64) ([]types.User, error)
+	ApproveFollowRequest(context.Context, int64, int64) error
 }
 
 type UserStore struct {
@@ -106,9 +126,9 @@ func (store *UserStore) GetByEmail(ctx context.Context, email string) (*types.Us
 func (store *UserStore) GetById(ctx context.Context, userId int64) (*types.User, error) {
 
 	query := `
-	SELECT users.id,username,email,password,created_at,roles.*
+	SELECT users.id,username,email,password,created_at,is_active,is_private,tokens_revoked_at,warning_count,suspended_until,roles.*
 	FROM users
-	JOIN rules ON (users.role_id = roles.id)
+	JOIN roles ON (users.role_id = roles.id)
 	WHERE users.id = $1 AND is_active = true
 	`
 
@@ -123,6 +143,11 @@ func (store *UserStore) GetById(ctx context.Context, userId int64) (*types.User,
 		&user.Email,
 		&user.Password,
 		&user.CreatedAt,
+		&user.IsActive,
+		&user.IsPrivate,
+		&user.TokensRevokedAt,
+		&user.WarningCount,
+		&user.SuspendedUntil,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -138,6 +163,8 @@ func (store *UserStore) GetById(ctx context.Context, userId int64) (*types.User,
 		}
 	}
 
+	user.RoleId = user.Role.ID
+
 	return user, nil
 }
 
@@ -156,6 +183,7 @@ func (store *UserStore) Follow(ctx context.Context, userId int64, followerId int
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrConflict
 		}
+		return err
 	}
 
 	return nil
@@ -176,6 +204,7 @@ func (store *UserStore) Unfollow(ctx context.Context, userId int64, unfollowerId
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrConflict
 		}
+		return err
 	}
 
 	return nil
@@ -337,26 +366,519 @@ func (store *UserStore) delete(ctx context.Context, tx *sql.Tx, userID int64) er
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, userID)
+	response, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rows == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
 
+// Delete removes the user, posts, comments, followers and invitations
+// of the user are removed by the database through ON DELETE CASCADE
 func (store *UserStore) Delete(ctx context.Context, userID int64) error {
 	return withTx(store.db, ctx, func(tx *sql.Tx) error {
 
+		if err := store.deleteUserInvitations(ctx, tx, userID); err != nil {
+			return err
+		}
+
 		if err := store.delete(ctx, tx, userID); err != nil {
 			return err
 		}
 
-		if err := store.deleteUserInvitations(ctx, tx, userID); err != nil {
+		return nil
+
+	})
+}
+
+func (store *UserStore) GetAll(ctx context.Context, fq UserFilterQuery) ([]types.User, error) {
+
+	query := `
+	SELECT u.id,u.username,u.email,u.created_at,u.is_active,u.is_private,u.warning_count,u.suspended_until,r.id,r.name,r.level,r.description
+	FROM users u
+	JOIN roles r ON r.id = u.role_id
+	WHERE
+		($1 = '' OR r.name = $1) AND
+		($2::boolean IS NULL OR u.is_active = $2) AND
+		(u.username ILIKE '%' || $3 || '%' OR u.email ILIKE '%' || $3 || '%')
+	ORDER BY u.created_at DESC
+	LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, fq.Role, fq.Active, fq.Search, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []types.User{}
+
+	for rows.Next() {
+		var u types.User
+
+		err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.Email,
+			&u.CreatedAt,
+			&u.IsActive,
+			&u.IsPrivate,
+			&u.WarningCount,
+			&u.SuspendedUntil,
+			&u.Role.ID,
+			&u.Role.Name,
+			&u.Role.Level,
+			&u.Role.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		u.RoleId = u.Role.ID
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// GetAnyById returns the user whether it is activated or not
+func (store *UserStore) GetAnyById(ctx context.Context, userId int64) (*types.User, error) {
+
+	query := `
+	SELECT u.id,u.username,u.email,u.created_at,u.is_active,u.is_private,u.warning_count,u.suspended_until,r.id,r.name,r.level,r.description
+	FROM users u
+	JOIN roles r ON r.id = u.role_id
+	WHERE u.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &types.User{}
+
+	err := store.db.QueryRowContext(ctx, query, userId).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+		&user.IsPrivate,
+		&user.WarningCount,
+		&user.SuspendedUntil,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	user.RoleId = user.Role.ID
+
+	return user, nil
+}
+
+func (store *UserStore) SetRole(ctx context.Context, userId int64, roleName string) error {
+
+	query := `
+	UPDATE users SET role_id = (SELECT id FROM roles WHERE name = $2)
+	WHERE id = $1 AND EXISTS (SELECT 1 FROM roles WHERE name = $2)
+	`
+
+	return store.exec(ctx, query, userId, roleName)
+}
+
+func (store *UserStore) SetActive(ctx context.Context, userId int64, active bool) error {
+
+	query := `UPDATE users SET is_active = $2 WHERE id = $1`
+
+	return store.exec(ctx, query, userId, active)
+}
+
+// RevokeTokens logs the user out everywhere, the tokens issued so far are rejected
+// and the api keys of the user are revoked with them
+func (store *UserStore) RevokeTokens(ctx context.Context, userId int64) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET tokens_revoked_at = NOW() WHERE id = $1`
+
+		response, err := tx.ExecContext(ctx, query, userId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := response.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		query = `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+		_, err = tx.ExecContext(ctx, query, userId)
+		return err
+	})
+}
+
+func (store *UserStore) Warn(ctx context.Context, userId int64) error {
+
+	query := `UPDATE users SET warning_count = warning_count + 1 WHERE id = $1`
+
+	return store.exec(ctx, query, userId)
+}
+
+func (store *UserStore) Suspend(ctx context.Context, userId int64, until time.Time) error {
+
+	query := `UPDATE users SET suspended_until = $2 WHERE id = $1`
+
+	return store.exec(ctx, query, userId, until)
+}
+
+// exec runs a statement that targets a single user and reports ErrNotFound when nothing changed
+func (store *UserStore) exec(ctx context.Context, query string, args ...any) error {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetRelationship returns how the viewer relates to the other user
+func (store *UserStore) GetRelationship(ctx context.Context, viewerId int64, userId int64) (Relationship, error) {
+
+	query := `
+	SELECT
+		EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2),
+		EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2 AND blocked_id = $1),
+		EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $1 AND muted_id = $2),
+		EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+		EXISTS (SELECT 1 FROM follow_requests WHERE user_id = $2 AND requester_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var rel Relationship
+
+	err := store.db.QueryRowContext(ctx, query, viewerId, userId).Scan(
+		&rel.Blocking,
+		&rel.BlockedBy,
+		&rel.Muting,
+		&rel.Following,
+		&rel.Requested,
+	)
+	if err != nil {
+		return Relationship{}, err
+	}
+
+	return rel, nil
+}
+
+// Block also removes the follows and follow requests between both users in either direction
+func (store *UserStore) Block(ctx context.Context, blockerId int64, blockedId int64) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO user_blocks (blocker_id,blocked_id) VALUES ($1,$2)`
+
+		if _, err := tx.ExecContext(ctx, query, blockerId, blockedId); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query = `
+		DELETE FROM followers
+		WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerId, blockedId); err != nil {
+			return err
+		}
+
+		query = `
+		DELETE FROM follow_requests
+		WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerId, blockedId); err != nil {
 			return err
 		}
 
 		return nil
+	})
+}
+
+func (store *UserStore) Unblock(ctx context.Context, blockerId int64, blockedId int64) error {
+
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	return store.exec(ctx, query, blockerId, blockedId)
+}
+
+func (store *UserStore) Mute(ctx context.Context, muterId int64, mutedId int64) error {
+
+	query := `INSERT INTO user_mutes (muter_id,muted_id) VALUES ($1,$2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := store.db.ExecContext(ctx, query, muterId, mutedId); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (store *UserStore) Unmute(ctx context.Context, muterId int64, mutedId int64) error {
+
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	return store.exec(ctx, query, muterId, mutedId)
+}
+
+func (store *UserStore) GetBlocked(ctx context.Context, userId int64) ([]types.User, error) {
+
+	query := `
+	SELECT u.id,u.username,ub.created_at
+	FROM user_blocks ub
+	JOIN users u ON u.id = ub.blocked_id
+	WHERE ub.blocker_id = $1
+	ORDER BY ub.created_at DESC
+	`
+
+	return store.getUserList(ctx, query, userId)
+}
+
+func (store *UserStore) GetMuted(ctx context.Context, userId int64) ([]types.User, error) {
+
+	query := `
+	SELECT u.id,u.username,um.created_at
+	FROM user_mutes um
+	JOIN users u ON u.id = um.muted_id
+	WHERE um.muter_id = $1
+	ORDER BY um.created_at DESC
+	`
+
+	return store.getUserList(ctx, query, userId)
+}
+
+// GetFollowing returns the users followed by userId
+func (store *UserStore) GetFollowing(ctx context.Context, userId int64) ([]types.User, error) {
+
+	query := `
+	SELECT u.id,u.username,f.created_at
+	FROM followers f
+	JOIN users u ON u.id = f.user_id
+	WHERE f.follower_id = $1
+	ORDER BY f.created_at DESC
+	`
+
+	return store.getUserList(ctx, query, userId)
+}
+
+// getUserList scans id, username and created_at of the returned users
+func (store *UserStore) getUserList(ctx context.Context, query string, args ...any) ([]types.User, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []types.User{}
+
+	for rows.Next() {
+		var u types.User
+
+		if err := rows.Scan(&u.ID, &u.Username, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// SetPrivate changes the account privacy, pending follow requests are
+// approved when the account becomes public and their requesters returned
+func (store *UserStore) SetPrivate(ctx context.Context, userId int64, private bool) ([]int64, error) {
+
+	approved := []int64{}
 
+	err := withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET is_private = $2 WHERE id = $1`
+
+		if _, err := tx.ExecContext(ctx, query, userId, private); err != nil {
+			return err
+		}
+
+		if private {
+			return nil
+		}
+
+		query = `
+		INSERT INTO followers (user_id,follower_id)
+		SELECT user_id, requester_id FROM follow_requests WHERE user_id = $1
+		ON CONFLICT DO NOTHING
+		RETURNING follower_id
+		`
+
+		rows, err := tx.QueryContext(ctx, query, userId)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var requesterId int64
+			if err := rows.Scan(&requesterId); err != nil {
+				return err
+			}
+			approved = append(approved, requesterId)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM follow_requests WHERE user_id = $1`
+
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return approved, nil
+}
+
+func (store *UserStore) RequestFollow(ctx context.Context, userId int64, requesterId int64) error {
+
+	query := `INSERT INTO follow_requests (user_id,requester_id) VALUES ($1,$2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := store.db.ExecContext(ctx, query, userId, requesterId); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CancelFollowRequest is used both by the requester to withdraw and by the owner to reject
+func (store *UserStore) CancelFollowRequest(ctx context.Context, userId int64, requesterId int64) error {
+
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	return store.exec(ctx, query, userId, requesterId)
+}
+
+func (store *UserStore) GetFollowRequests(ctx context.Context, userId int64) ([]types.User, error) {
+
+	query := `
+	SELECT u.id,u.username,fr.created_at
+	FROM follow_requests fr
+	JOIN users u ON u.id = fr.requester_id
+	WHERE fr.user_id = $1
+	ORDER BY fr.created_at ASC
+	`
+
+	return store.getUserList(ctx, query, userId)
+}
+
+func (store *UserStore) ApproveFollowRequest(ctx context.Context, userId int64, requesterId int64) error {
+
+	return withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+		response, err := tx.ExecContext(ctx, query, userId, requesterId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := response.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		query = `INSERT INTO followers (user_id,follower_id) VALUES ($1,$2) ON CONFLICT DO NOTHING`
+
+		if _, err := tx.ExecContext(ctx, query, userId, requesterId); err != nil {
+			return err
+		}
+
+		return nil
 	})
 }
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"social/internal/types"
+)
+
+// Viewer is the user a read is performed for, list queries filter
+// their rows with it and single records are checked with its Can* methods
+type Viewer struct {
+	ID int64
+
+	// moderators can see content hidden through moderation
+	Moderator bool
+}
+
+// Relationship is how the viewer relates to another user
+type Relationship struct {
+	Blocking  bool `json:"blocking"`
+	BlockedBy bool `json:"blocked_by"`
+	Muting    bool `json:"muting"`
+	Following bool `json:"following"`
+	Requested bool `json:"requested"`
+}
+
+func (rel Relationship) Blocked() bool {
+	return rel.Blocking || rel.BlockedBy
+}
+
+// CanViewPost checks a single post, rel is the relationship of the viewer to the author
+func (viewer Viewer) CanViewPost(post *types.Post, rel Relationship) bool {
+
+	if post.DeletedAt != nil {
+		return false
+	}
+
+	if post.HiddenAt != nil && !viewer.Moderator {
+		return false
+	}
+
+	if post.UserId == viewer.ID {
+		return true
+	}
+
+	// drafts and scheduled posts are only for their author
+	if post.Status != "" && post.Status != types.PostPublished {
+		return false
+	}
+
+	if rel.Blocked() {
+		return false
+	}
+
+	// posts of private accounts are only for approved followers
+	if post.User.IsPrivate && !rel.Following && !viewer.Moderator {
+		return false
+	}
+
+	switch post.Visibility {
+	case types.VisibilityPublic, "":
+		return true
+	case types.VisibilityFollowers:
+		return rel.Following || viewer.Moderator
+	case types.VisibilityMentioned:
+		return slices.Contains(post.MentionedUserIds, viewer.ID) || viewer.Moderator
+	default:
+		// private posts are only for their author
+		return false
+	}
+}
+
+// CanViewComment checks a single comment, rel is the relationship of the viewer to the author
+func (viewer Viewer) CanViewComment(comment *types.Comment, rel Relationship) bool {
+
+	if comment.DeletedAt != nil {
+		return false
+	}
+
+	if comment.HiddenAt != nil && !viewer.Moderator {
+		return false
+	}
+
+	if comment.UserId != viewer.ID && rel.Blocked() {
+		return false
+	}
+
+	return true
+}
+
+// CanViewUser checks a profile, rel is the relationship of the viewer to the user
+func (viewer Viewer) CanViewUser(user *types.User, rel Relationship) bool {
+	return user.ID == viewer.ID || !rel.Blocked()
+}
+
+// visiblePostSQL is the list query counterpart of CanViewPost, viewer and moderator
+// are the placeholders of the viewer id and moderator flag, post the alias of the posts table.
+// Lists only hold published posts, authors reach their drafts through their own listing
+func visiblePostSQL(viewer, moderator, post string) string {
+	return fmt.Sprintf(`(
+		%[3]s.deleted_at IS NULL AND
+		%[3]s.status = 'published' AND
+		(%[2]s OR %[3]s.hidden_at IS NULL) AND
+		(
+			%[3]s.user_id = %[1]s OR (
+				%[4]s AND
+				(%[2]s OR NOT EXISTS (SELECT 1 FROM users vu WHERE vu.id = %[3]s.user_id AND vu.is_private) OR %[5]s) AND
+				(
+					%[3]s.visibility = 'public' OR
+					(%[3]s.visibility = 'followers' AND (%[2]s OR %[5]s)) OR
+					(%[3]s.visibility = 'mentioned' AND (%[2]s OR EXISTS (
+						SELECT 1 FROM post_mentions vpm WHERE vpm.post_id = %[3]s.id AND vpm.user_id = %[1]s
+					)))
+				)
+			)
+		)
+	)`, viewer, moderator, post, notBlockedSQL(viewer, post+".user_id"), followingSQL(viewer, post+".user_id"))
+}
+
+func followingSQL(viewer, author string) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = %[2]s AND vf.follower_id = %[1]s)`, viewer, author)
+}
+
+// notBlockedSQL is the list query counterpart of Relationship.Blocked,
+// viewer is the placeholder of the viewer id and author the column of the other user
+func notBlockedSQL(viewer, author string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = %[1]s AND ub.blocked_id = %[2]s) OR (ub.blocker_id = %[2]s AND ub.blocked_id = %[1]s)
+	)`, viewer, author)
+}
+
+// notMutedSQL hides the content of users muted by the viewer
+func notMutedSQL(viewer, author string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM user_mutes um WHERE um.muter_id = %[1]s AND um.muted_id = %[2]s
+	)`, viewer, author)
+}
@@ -0,0 +1,426 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"social/internal/types"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type IWebhookStore interface {
+	Create(ctx context.Context, webhook *types.Webhook) error
+	GetById(ctx context.Context, webhookId int64) (*types.Webhook, error)
+	GetByUserId(ctx context.Context, userId int64) ([]types.Webhook, error)
+	Update(ctx context.Context, webhook *types.Webhook) error
+	Delete(ctx context.Context, webhookId int64) error
+	CreateDeliveries(ctx context.Context, event string, payload json.RawMessage, userIds []int64) (int64, error)
+	CreateReplay(ctx context.Context, delivery *types.WebhookDelivery, claimedUntil time.Time) (*types.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, webhookId int64, deliveryId int64) (*types.WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, webhookId int64, dq DeliveryQuery) ([]types.WebhookDelivery, error)
+	ClaimDue(ctx context.Context, limit int, claimedUntil time.Time) ([]types.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *types.WebhookDelivery, disableAfter int) (bool, error)
+}
+
+// WebhookStore keeps the webhook subscriptions and the log of their deliveries.
+// pending deliveries are claimed until a point in time so a single sender attempts them
+type WebhookStore struct {
+	db *sql.DB
+}
+
+const webhookColumns = `w.id,w.user_id,w.global,w.url,w.secret,w.events,w.active,w.failure_count,w.disabled_at,w.created_at,w.updated_at`
+
+const deliveryColumns = `d.id,d.webhook_id,d.event,d.payload,d.status,d.attempts,d.next_attempt_at,d.response_status,
+	d.error,d.duration_ms,d.replay_of,d.created_at,d.delivered_at`
+
+func scanWebhook(row scanner, w *types.Webhook, extra ...any) error {
+	return row.Scan(append([]any{
+		&w.ID,
+		&w.UserId,
+		&w.Global,
+		&w.URL,
+		&w.Secret,
+		pq.Array(&w.Events),
+		&w.Active,
+		&w.FailureCount,
+		&w.DisabledAt,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	}, extra...)...)
+}
+
+// scanDelivery reads the delivery columns followed by the webhook columns
+func scanDelivery(row scanner) (types.WebhookDelivery, error) {
+
+	var d types.WebhookDelivery
+	d.Webhook = &types.Webhook{}
+
+	err := scanWebhook(row, d.Webhook,
+		&d.ID,
+		&d.WebhookId,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseStatus,
+		&d.Error,
+		&d.DurationMs,
+		&d.ReplayOf,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+	)
+
+	return d, err
+}
+
+func (store *WebhookStore) Create(ctx context.Context, webhook *types.Webhook) error {
+
+	query := `
+	INSERT INTO webhooks (user_id,global,url,secret,events)
+	VALUES ($1,$2,$3,$4,$5)
+	RETURNING id,active,created_at,updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return store.db.QueryRowContext(ctx, query,
+		webhook.UserId,
+		webhook.Global,
+		webhook.URL,
+		webhook.Secret,
+		pq.Array(webhook.Events),
+	).Scan(
+		&webhook.ID,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+}
+
+func (store *WebhookStore) GetById(ctx context.Context, webhookId int64) (*types.Webhook, error) {
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks w WHERE w.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var webhook types.Webhook
+
+	if err := scanWebhook(store.db.QueryRowContext(ctx, query, webhookId), &webhook); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (store *WebhookStore) GetByUserId(ctx context.Context, userId int64) ([]types.Webhook, error) {
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks w WHERE w.user_id = $1 ORDER BY w.id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []types.Webhook{}
+
+	for rows.Next() {
+		var webhook types.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Update saves the url, events and active flag, reactivating a webhook clears its failures
+func (store *WebhookStore) Update(ctx context.Context, webhook *types.Webhook) error {
+
+	query := `
+	UPDATE webhooks SET
+		url = $2,
+		events = $3,
+		active = $4,
+		failure_count = CASE WHEN $4 AND NOT active THEN 0 ELSE failure_count END,
+		disabled_at = CASE WHEN $4 THEN NULL ELSE disabled_at END,
+		updated_at = NOW()
+	WHERE id = $1
+	RETURNING failure_count,disabled_at,updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := store.db.QueryRowContext(ctx, query,
+		webhook.ID,
+		webhook.URL,
+		pq.Array(webhook.Events),
+		webhook.Active,
+	).Scan(
+		&webhook.FailureCount,
+		&webhook.DisabledAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *WebhookStore) Delete(ctx context.Context, webhookId int64) error {
+
+	query := `DELETE FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	response, err := store.db.ExecContext(ctx, query, webhookId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := response.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries queues the event for the active webhooks subscribed to it, the global ones and
+// the ones of userIds, the users the event is about. the deliveries are due right away
+func (store *WebhookStore) CreateDeliveries(ctx context.Context, event string, payload json.RawMessage, userIds []int64) (int64, error) {
+
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id,event,payload,next_attempt_at)
+	SELECT w.id,$1,$2,NOW()
+	FROM webhooks w
+	WHERE w.active AND $1 = ANY(w.events) AND (w.global OR w.user_id = ANY($3))
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := store.db.ExecContext(ctx, query, event, payload, pq.Array(userIds))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// CreateReplay queues a new delivery of the event of an earlier one, claimed by the caller
+func (store *WebhookStore) CreateReplay(ctx context.Context, delivery *types.WebhookDelivery, claimedUntil time.Time) (*types.WebhookDelivery, error) {
+
+	query := `
+	WITH d AS (
+		INSERT INTO webhook_deliveries (webhook_id,event,payload,next_attempt_at,replay_of)
+		SELECT webhook_id,event,payload,$2,id FROM webhook_deliveries WHERE id = $1
+		RETURNING *
+	)
+	SELECT ` + webhookColumns + `,` + deliveryColumns + `
+	FROM d
+	JOIN webhooks w ON w.id = d.webhook_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	replay, err := scanDelivery(store.db.QueryRowContext(ctx, query, delivery.ID, claimedUntil))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &replay, nil
+}
+
+func (store *WebhookStore) GetDelivery(ctx context.Context, webhookId int64, deliveryId int64) (*types.WebhookDelivery, error) {
+
+	query := `
+	SELECT ` + webhookColumns + `,` + deliveryColumns + `
+	FROM webhook_deliveries d
+	JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.id = $1 AND d.webhook_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	delivery, err := scanDelivery(store.db.QueryRowContext(ctx, query, deliveryId, webhookId))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &delivery, nil
+}
+
+// GetDeliveries pages through the delivery log of a webhook, newest first
+func (store *WebhookStore) GetDeliveries(ctx context.Context, webhookId int64, dq DeliveryQuery) ([]types.WebhookDelivery, error) {
+
+	query := `
+	SELECT ` + webhookColumns + `,` + deliveryColumns + `
+	FROM webhook_deliveries d
+	JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.webhook_id = $1 AND ($4 = '' OR d.status = $4)
+	ORDER BY d.id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, webhookId, dq.Limit, dq.Offset, dq.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows)
+}
+
+// ClaimDue claims up to limit pending deliveries of active webhooks whose next attempt is due,
+// rows locked by another sender are skipped
+func (store *WebhookStore) ClaimDue(ctx context.Context, limit int, claimedUntil time.Time) ([]types.WebhookDelivery, error) {
+
+	query := `
+	WITH d AS (
+		UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT dd.id FROM webhook_deliveries dd
+			JOIN webhooks dw ON dw.id = dd.webhook_id
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= NOW() AND dw.active
+			ORDER BY dd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING *
+	)
+	SELECT ` + webhookColumns + `,` + deliveryColumns + `
+	FROM d
+	JOIN webhooks w ON w.id = d.webhook_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := store.db.QueryContext(ctx, query, limit, claimedUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows)
+}
+
+func scanDeliveries(rows *sql.Rows) ([]types.WebhookDelivery, error) {
+
+	deliveries := []types.WebhookDelivery{}
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt saves the outcome of an attempt and keeps the count of consecutive failures of the webhook,
+// which is disabled once it reaches disableAfter. disabled tells whether this attempt disabled it
+func (store *WebhookStore) RecordAttempt(ctx context.Context, delivery *types.WebhookDelivery, disableAfter int) (bool, error) {
+
+	disabled := false
+
+	err := withTx(store.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = $3,
+			next_attempt_at = $4,
+			response_status = $5,
+			error = $6,
+			duration_ms = $7,
+			delivered_at = $8
+		WHERE id = $1
+		`
+
+		_, err := tx.ExecContext(ctx, query,
+			delivery.ID,
+			delivery.Status,
+			delivery.Attempts,
+			delivery.NextAttemptAt,
+			delivery.ResponseStatus,
+			delivery.Error,
+			delivery.DurationMs,
+			delivery.DeliveredAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if delivery.Status == types.DeliverySucceeded {
+			_, err := tx.ExecContext(ctx, `UPDATE webhooks SET failure_count = 0 WHERE id = $1`, delivery.WebhookId)
+			return err
+		}
+
+		query = `
+		UPDATE webhooks SET
+			failure_count = failure_count + 1,
+			active = failure_count + 1 < $2,
+			disabled_at = CASE WHEN failure_count + 1 >= $2 THEN NOW() ELSE NULL END,
+			updated_at = NOW()
+		WHERE id = $1 AND active
+		RETURNING NOT active
+		`
+
+		err = tx.QueryRowContext(ctx, query, delivery.WebhookId, disableAfter).Scan(&disabled)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+
+	return disabled, err
+}
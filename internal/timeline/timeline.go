@@ -0,0 +1,138 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"social/internal/store"
+	"time"
+)
+
+// how many jobs are run per drain, and how often the jobs queued by other instances are looked for
+const (
+	batchSize    = 100
+	pollInterval = time.Second * 5
+)
+
+// a failed job waits 10s more with every attempt, it is set aside after the last one
+var retry = store.JobRetry{
+	MaxAttempts: 8,
+	Backoff:     time.Second * 10,
+}
+
+// Service writes the home timelines off the request path. jobs are persisted and run one at a time
+// in the order they were queued, so a repost is never removed before it was added
+type Service struct {
+	store store.ITimelineStore
+
+	// signals the worker that jobs were queued
+	wake chan struct{}
+
+	// users with at least FanoutThreshold followers are merged into the timelines at read time
+	FanoutThreshold int
+
+	// how many posts of a newly followed user are copied into the timeline of the follower
+	BackfillSize int
+}
+
+func NewService(store store.ITimelineStore, fanoutThreshold, backfillSize int) *Service {
+	return &Service{
+		store:           store,
+		wake:            make(chan struct{}, 1),
+		FanoutThreshold: fanoutThreshold,
+		BackfillSize:    backfillSize,
+	}
+}
+
+// Run drains the queued jobs until ctx is done
+func (s *Service) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means more are waiting
+		for {
+			ran, err := s.store.Drain(ctx, batchSize, retry, s.run)
+			if err != nil {
+				log.Println("error draining timeline jobs: ", err.Error())
+			}
+			if err != nil || ran < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// run makes one attempt at a job, failures are retried by the store
+func (s *Service) run(ctx context.Context, job store.TimelineJob) error {
+
+	var err error
+
+	switch job.Kind {
+	case store.TimelinePost:
+		_, err = s.store.AddPost(ctx, job.PostId, s.FanoutThreshold)
+	case store.TimelineRepost:
+		_, err = s.store.AddRepost(ctx, job.UserId, job.PostId, s.FanoutThreshold)
+	case store.TimelineUnrepost:
+		err = s.store.RemoveRepost(ctx, job.UserId, job.PostId)
+	case store.TimelineFollow:
+		err = s.store.Backfill(ctx, job.FollowerId, job.UserId, s.BackfillSize)
+	case store.TimelineUnfollow:
+		err = s.store.Prune(ctx, job.FollowerId, job.UserId)
+	default:
+		err = fmt.Errorf("unknown timeline job %s", job.Kind)
+	}
+
+	if err != nil {
+		log.Printf("error running timeline %s job %d (attempt %d): %s", job.Kind, job.ID, job.Attempts+1, err.Error())
+	}
+
+	return err
+}
+
+// enqueue persists the job, failures are logged and never fail the caller
+func (s *Service) enqueue(ctx context.Context, job store.TimelineJob) {
+
+	if err := s.store.Enqueue(context.WithoutCancel(ctx), job); err != nil {
+		log.Printf("error queuing timeline %s job: %s", job.Kind, err.Error())
+		return
+	}
+
+	// the worker is already signaled when the channel is full
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// PostPublished pushes a post to the timelines of the followers of its author
+func (s *Service) PostPublished(ctx context.Context, postId int64) {
+	s.enqueue(ctx, store.TimelineJob{Kind: store.TimelinePost, PostId: postId})
+}
+
+// Reposted pushes a repost to the timelines of the followers of the user who reposted
+func (s *Service) Reposted(ctx context.Context, userId, postId int64) {
+	s.enqueue(ctx, store.TimelineJob{Kind: store.TimelineRepost, UserId: userId, PostId: postId})
+}
+
+func (s *Service) Unreposted(ctx context.Context, userId, postId int64) {
+	s.enqueue(ctx, store.TimelineJob{Kind: store.TimelineUnrepost, UserId: userId, PostId: postId})
+}
+
+// Followed backfills the timeline of a new follower
+func (s *Service) Followed(ctx context.Context, userId, followerId int64) {
+	s.enqueue(ctx, store.TimelineJob{Kind: store.TimelineFollow, UserId: userId, FollowerId: followerId})
+}
+
+// Unfollowed prunes the timeline of a former follower
+func (s *Service) Unfollowed(ctx context.Context, userId, followerId int64) {
+	s.enqueue(ctx, store.TimelineJob{Kind: store.TimelineUnfollow, UserId: userId, FollowerId: followerId})
+}
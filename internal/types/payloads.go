@@ -1,14 +1,28 @@
 package types
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title      string   `json:"title" validate:"required,max=100"`
+	Content    string   `json:"content" validate:"required,max=1000"`
+	Tags       []string `json:"tags" validate:"max=10"`
+	Visibility string   `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+	Draft      bool     `json:"draft"`
+	PublishAt  *string  `json:"publish_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+
+	QuotePostId *int64 `json:"quote_post_id"`
+}
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title      *string `json:"title" validate:"omitempty,max=100"`
+	Content    *string `json:"content" validate:"omitempty,max=1000"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
 }
 
 type FollowUserPayload struct {
@@ -27,3 +41,58 @@ type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
 }
+
+type CreateApiKeyPayload struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Scopes    []string `json:"scopes" validate:"max=10,dive,oneof=posts:read posts:write users:read users:write feed:read notifications:read messages:read messages:write"`
+	ExpiresIn *int     `json:"expires_in_days" validate:"omitempty,gte=1,lte=365"`
+}
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,max=25"`
+}
+
+type CreateReportPayload struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment"`
+	TargetId   int64  `json:"target_id" validate:"required,gte=1"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence nudity misinformation other"`
+	Details    string `json:"details" validate:"max=1000"`
+}
+
+type ResolveReportPayload struct {
+	Action      string `json:"action" validate:"required,oneof=dismiss hide delete warn suspend"`
+	Note        string `json:"note" validate:"max=1000"`
+	SuspendDays int    `json:"suspend_days" validate:"required_if=Action suspend,omitempty,gte=1,lte=365"`
+}
+
+type UpdateUserPayload struct {
+	IsPrivate *bool `json:"is_private"`
+}
+
+type SchedulePostPayload struct {
+	PublishAt string `json:"publish_at" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// CreateConversationPayload starts a one-to-one conversation with a single participant and no title,
+// a group otherwise. the creator is always a participant
+type CreateConversationPayload struct {
+	ParticipantIds []int64 `json:"participant_ids" validate:"required,min=1,max=9,dive,gte=1"`
+	Title          string  `json:"title" validate:"max=100"`
+}
+
+type CreateMessagePayload struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type CreateWebhookPayload struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2000"`
+	Events []string `json:"events" validate:"required,min=1,max=10,dive,oneof=post.created user.followed comment.created"`
+	Global bool     `json:"global"`
+}
+
+// UpdateWebhookPayload setting active back to true clears the failures of a disabled webhook
+type UpdateWebhookPayload struct {
+	URL    *string  `json:"url" validate:"omitempty,http_url,max=2000"`
+	Events []string `json:"events" validate:"omitempty,min=1,max=10,dive,oneof=post.created user.followed comment.created"`
+	Active *bool    `json:"active"`
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Post struct {
 	ID        int64     `json:"id"`
 	Content   string    `json:"content"`
@@ -11,11 +16,100 @@ type Post struct {
 	Comments  []Comment `json:"comments"`
 	User      User      `json:"user"`
 	Version   int
+	HiddenAt  *string `json:"hidden_at,omitempty"`
+
+	Visibility       string  `json:"visibility"`
+	MentionedUserIds []int64 `json:"-"`
+
+	// the tags given by the author, Tags adds the hashtags of the content to them
+	ExplicitTags []string `json:"-"`
+
+	Status    string  `json:"status"`
+	PublishAt *string `json:"publish_at,omitempty"`
+
+	Edited   bool    `json:"edited"`
+	EditedAt *string `json:"edited_at,omitempty"`
+
+	DeletedAt *string `json:"deleted_at,omitempty"`
+	DeletedBy *int64  `json:"-"`
+
+	QuotePostId *int64 `json:"quote_post_id,omitempty"`
+	QuotedPost  *Post  `json:"quoted_post,omitempty"`
+
+	// whether the user reading the post has bookmarked it
+	Bookmarked bool `json:"bookmarked"`
+
+	Entities Entities `json:"entities"`
+}
+
+// Entities are the structured parts of a text
+type Entities struct {
+	Mentions []MentionEntity `json:"mentions"`
+}
+
+// MentionEntity is a mention of a user, Start and End are character offsets in the text with the @ included
+type MentionEntity struct {
+	UserId   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+	VisibilityPrivate   = "private"
+)
+
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
+// PostRevision is a post as it was before one of its edits
+type PostRevision struct {
+	ID         int64  `json:"id"`
+	PostId     int64  `json:"post_id"`
+	Version    int    `json:"version"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Visibility string `json:"visibility"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type TrendingTag struct {
+	Tag        string  `json:"tag"`
+	Score      float64 `json:"score"`
+	PostsCount int     `json:"posts_count"`
 }
 
 type PostWithMetadata struct {
 	Post
 	CommentsCount int `json:"comments_count"`
+	RepostsCount  int `json:"reposts_count"`
+
+	// followed users who reposted the post, empty when it is in the feed through its author
+	RepostedBy []string `json:"reposted_by,omitempty"`
+
+	// pinned by its author, only set on profile timelines
+	Pinned bool `json:"pinned,omitempty"`
+
+	// the comment of the profile user the post is listed for, only set on profile timelines with replies
+	Reply *Comment `json:"reply,omitempty"`
+
+	// how the ranked feed scored the post, only returned in debug mode
+	Score *FeedScore `json:"score,omitempty"`
+}
+
+// FeedScore is the breakdown of the score of a post in the ranked feed, every part is already weighted
+type FeedScore struct {
+	Total       float64 `json:"total"`
+	Recency     float64 `json:"recency"`
+	Engagement  float64 `json:"engagement"`
+	Affinity    float64 `json:"affinity"`
+	TagInterest float64 `json:"tag_interest"`
 }
 
 type User struct {
@@ -25,8 +119,14 @@ type User struct {
 	Password  string `json:"-"`                    // password bytea
 	CreatedAt string `json:"created_at,omitempty"` // created_at timestamp (0)
 	IsActive  bool   `json:"is_active,omitempty"`
+	IsPrivate bool   `json:"is_private"`
 	RoleId    int64  `json:"role_id"`
 	Role      Role   `json:"role"`
+
+	// tokens issued before this moment are rejected, set when a user is force logged out
+	TokensRevokedAt *time.Time `json:"-"`
+	WarningCount    int        `json:"warning_count,omitempty"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
 }
 
 type UserWithToken struct {
@@ -50,17 +150,214 @@ type UserWithToken struct {
 // }
 
 type Comment struct {
-	ID        int64  `json:"id"`         // id bigint
-	PostId    int64  `json:"post_id"`    // post_id bigint
-	UserId    int64  `json:"user_id"`    // user_id bigint
-	Content   string `json:"content"`    // content text
-	CreatedAt string `json:"created_at"` // created_at timestamp(0)
-	User      User   `json:"user"`
+	ID        int64   `json:"id"`         // id bigint
+	PostId    int64   `json:"post_id"`    // post_id bigint
+	UserId    int64   `json:"user_id"`    // user_id bigint
+	Content   string  `json:"content"`    // content text
+	CreatedAt string  `json:"created_at"` // created_at timestamp(0)
+	User      User    `json:"user"`
+	HiddenAt  *string `json:"hidden_at,omitempty"`
+	DeletedAt *string `json:"deleted_at,omitempty"`
+	DeletedBy *int64  `json:"-"`
+
+	MentionedUserIds []int64  `json:"-"`
+	Entities         Entities `json:"entities"`
 }
 
 type Role struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Level       int64    `json:"level"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type Permission struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
-	Level       int64  `json:"level"`
 	Description string `json:"description"`
 }
+
+type ApiKey struct {
+	ID         int64    `json:"id"`
+	UserId     int64    `json:"user_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	KeyHash    string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	RevokedAt  *string  `json:"revoked_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+type ApiKeyWithSecret struct {
+	*ApiKey
+	Key string `json:"key"`
+}
+
+type AuditLog struct {
+	ID         int64           `json:"id"`
+	ActorId    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetId   *int64          `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestId  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+type Report struct {
+	ID           int64   `json:"id"`
+	ReporterId   int64   `json:"reporter_id"`
+	TargetType   string  `json:"target_type"`
+	TargetId     int64   `json:"target_id"`
+	TargetUserId int64   `json:"target_user_id"`
+	Reason       string  `json:"reason"`
+	Details      string  `json:"details"`
+	Status       string  `json:"status"`
+	Resolution   string  `json:"resolution,omitempty"`
+	Note         string  `json:"note,omitempty"`
+	ResolvedBy   *int64  `json:"resolved_by,omitempty"`
+	ResolvedAt   *string `json:"resolved_at,omitempty"`
+	CreatedAt    string  `json:"created_at"`
+
+	// the reported content was deleted since, only dismissing is left
+	TargetDeleted bool `json:"target_deleted"`
+}
+
+const (
+	NotificationFollow         = "follow"
+	NotificationFollowRequest  = "follow_request"
+	NotificationFollowAccepted = "follow_accepted"
+	NotificationMention        = "mention"
+	NotificationComment        = "comment"
+	NotificationRepost         = "repost"
+	NotificationQuote          = "quote"
+)
+
+// NotificationTypes are the types a user can turn on and off
+var NotificationTypes = []string{
+	NotificationFollow,
+	NotificationFollowRequest,
+	NotificationFollowAccepted,
+	NotificationMention,
+	NotificationComment,
+	NotificationRepost,
+	NotificationQuote,
+}
+
+type Notification struct {
+	ID         int64   `json:"id"`
+	UserId     int64   `json:"user_id"`
+	ActorId    int64   `json:"actor_id"`
+	Type       string  `json:"type"`
+	TargetType string  `json:"target_type"`
+	TargetId   int64   `json:"target_id"`
+	ReadAt     *string `json:"read_at,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// NotificationGroup gathers the notifications of the same type about the same target,
+// id is the one of the latest notification of the group
+type NotificationGroup struct {
+	ID          int64  `json:"id"`
+	Type        string `json:"type"`
+	TargetType  string `json:"target_type"`
+	TargetId    int64  `json:"target_id"`
+	Actors      []User `json:"actors"`
+	ActorsCount int    `json:"actors_count"`
+	Message     string `json:"message"`
+	Read        bool   `json:"read"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Conversation is a one-to-one or group conversation, UnreadCount and LastMessage are for the user reading it
+type Conversation struct {
+	ID           int64                     `json:"id"`
+	IsGroup      bool                      `json:"is_group"`
+	Title        string                    `json:"title,omitempty"`
+	CreatedBy    *int64                    `json:"created_by,omitempty"`
+	Participants []ConversationParticipant `json:"participants"`
+	LastMessage  *Message                  `json:"last_message,omitempty"`
+	UnreadCount  int                       `json:"unread_count"`
+	CreatedAt    string                    `json:"created_at"`
+	UpdatedAt    string                    `json:"updated_at"`
+}
+
+// ConversationParticipant carries the read receipt of a participant, the last message it has read
+type ConversationParticipant struct {
+	UserId            int64   `json:"user_id"`
+	Username          string  `json:"username"`
+	LastReadMessageId *int64  `json:"last_read_message_id"`
+	LastReadAt        *string `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             int64  `json:"id"`
+	ConversationId int64  `json:"conversation_id"`
+	SenderId       int64  `json:"sender_id"`
+	Sender         User   `json:"sender"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+}
+
+const (
+	WebhookPostCreated    = "post.created"
+	WebhookUserFollowed   = "user.followed"
+	WebhookCommentCreated = "comment.created"
+)
+
+// WebhookEvents are the events a webhook can subscribe to
+var WebhookEvents = []string{
+	WebhookPostCreated,
+	WebhookUserFollowed,
+	WebhookCommentCreated,
+}
+
+type Webhook struct {
+	ID           int64    `json:"id"`
+	UserId       int64    `json:"user_id"`
+	Global       bool     `json:"global"`
+	URL          string   `json:"url"`
+	Secret       string   `json:"-"`
+	Events       []string `json:"events"`
+	Active       bool     `json:"active"`
+	FailureCount int      `json:"failure_count"`
+	DisabledAt   *string  `json:"disabled_at,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+type WebhookWithSecret struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is an event sent to a webhook with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookId      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMs     *int            `json:"duration_ms,omitempty"`
+	ReplayOf       *int64          `json:"replay_of,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// the webhook the delivery is sent to
+	Webhook *Webhook `json:"-"`
+}
@@ -0,0 +1,310 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// how long a sender holds a delivery before another one may attempt it
+const claimDuration = time.Minute * 5
+
+// the first retry waits baseBackoff, every following one twice as long up to maxBackoff
+const (
+	baseBackoff = time.Second * 30
+	maxBackoff  = time.Hour * 6
+)
+
+// ErrBlockedAddress is returned for webhooks resolving to loopback, private or link-local addresses
+var ErrBlockedAddress = errors.New("webhook address is not allowed")
+
+// Dispatcher sends the events to the webhooks subscribed to them. deliveries are queued in the store
+// and attempted by RetryDue, failed attempts are retried with exponential backoff until MaxAttempts
+type Dispatcher struct {
+	store  store.IWebhookStore
+	client *http.Client
+
+	// signals the retry loop that new deliveries are due
+	wake chan struct{}
+
+	MaxAttempts int
+
+	// consecutive failed attempts after which a webhook is disabled
+	DisableAfter int
+}
+
+func NewDispatcher(store store.IWebhookStore, maxAttempts, disableAfter int) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		client:       newClient(),
+		wake:         make(chan struct{}, 1),
+		MaxAttempts:  maxAttempts,
+		DisableAfter: disableAfter,
+	}
+}
+
+// newClient does not follow redirects nor use a proxy, every connection goes through publicOnly
+func newClient() *http.Client {
+
+	dialer := &net.Dialer{
+		Timeout: time.Second * 5,
+		Control: publicOnly,
+	}
+
+	return &http.Client{
+		Timeout:   time.Second * 10,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicOnly refuses connections to the internal network. it runs on the resolved address
+// right before connecting, so a webhook can not get around it by changing its dns records
+func publicOnly(network, address string, c syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if !IsPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+	}
+
+	return nil
+}
+
+// IsPublic tells whether an address can be the destination of a webhook
+func IsPublic(ip netip.Addr) bool {
+
+	ip = ip.Unmap()
+
+	return ip.IsValid() &&
+		!ip.IsUnspecified() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast()
+}
+
+// NewSecret returns a random secret for signing the deliveries of a webhook
+func NewSecret() (string, error) {
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// Sign is the signature of a delivery, the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+// receivers recompute it and compare it with the X-Webhook-Signature header, "sha256=<signature>"
+func Sign(secret string, timestamp int64, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish queues an event for the webhooks subscribed to it, userIds are the users the event is about.
+// the deliveries are due right away and sent by the retry loop, failures are logged and never fail the caller
+func (d *Dispatcher) Publish(ctx context.Context, event string, userIds []int64, data any) {
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("error encoding %s webhook payload: %s", event, err.Error())
+		return
+	}
+
+	queued, err := d.store.CreateDeliveries(ctx, event, payload, userIds)
+	if err != nil {
+		log.Printf("error queuing %s webhook deliveries: %s", event, err.Error())
+		return
+	}
+
+	if queued == 0 {
+		return
+	}
+
+	// the loop is already signaled when the channel is full
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wake is signaled when Publish queues new deliveries
+func (d *Dispatcher) Wake() <-chan struct{} {
+	return d.wake
+}
+
+// Replay sends the event of a delivery again as a new delivery and returns it
+func (d *Dispatcher) Replay(ctx context.Context, delivery *types.WebhookDelivery) (*types.WebhookDelivery, error) {
+
+	replay, err := d.store.CreateReplay(ctx, delivery, time.Now().Add(claimDuration))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Deliver(ctx, replay); err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
+// RetryDue attempts the deliveries whose next attempt is due and returns how many were attempted
+func (d *Dispatcher) RetryDue(ctx context.Context, limit int) (int, error) {
+
+	deliveries, err := d.store.ClaimDue(ctx, limit, time.Now().Add(claimDuration))
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		d.attempt(ctx, &deliveries[i])
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *types.WebhookDelivery) {
+	if err := d.Deliver(ctx, delivery); err != nil {
+		log.Printf("error recording webhook delivery %d: %s", delivery.ID, err.Error())
+	}
+}
+
+// Deliver makes one attempt at a delivery and records its outcome, the returned error is about the recording.
+// any 2xx response is a success, anything else is retried until the attempts run out
+func (d *Dispatcher) Deliver(ctx context.Context, delivery *types.WebhookDelivery) error {
+
+	delivery.Attempts++
+
+	start := time.Now()
+	status, err := d.send(ctx, delivery)
+	duration := int(time.Since(start).Milliseconds())
+
+	delivery.DurationMs = &duration
+	delivery.ResponseStatus = nil
+	delivery.Error = ""
+
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+	case status < 200 || status > 299:
+		delivery.Error = fmt.Sprintf("unexpected response status %d", status)
+	}
+
+	switch {
+	case delivery.Error == "":
+		now := time.Now()
+		delivery.Status = types.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = types.DeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := time.Now().Add(Backoff(delivery.Attempts))
+		delivery.Status = types.DeliveryPending
+		delivery.NextAttemptAt = &next
+	}
+
+	disabled, err := d.store.RecordAttempt(context.WithoutCancel(ctx), delivery, d.DisableAfter)
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		log.Printf("webhook %d disabled after %d consecutive failures", delivery.WebhookId, d.DisableAfter)
+	}
+
+	return nil
+}
+
+// Backoff is the wait before the attempt following the given number of attempts
+func Backoff(attempts int) time.Duration {
+
+	backoff := baseBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// send posts the signed delivery and returns the response status, the body of the response is not read
+func (d *Dispatcher) send(ctx context.Context, delivery *types.WebhookDelivery) (int, error) {
+
+	body, err := json.Marshal(map[string]any{
+		"id":         delivery.ID,
+		"event":      delivery.Event,
+		"created_at": delivery.CreatedAt,
+		"data":       delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(delivery.Webhook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return res.StatusCode, nil
+}
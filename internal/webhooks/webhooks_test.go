@@ -0,0 +1,194 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"social/internal/store"
+	"social/internal/types"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeWebhookStore struct {
+	store.IWebhookStore
+	recorded []types.WebhookDelivery
+}
+
+func (s *fakeWebhookStore) RecordAttempt(ctx context.Context, delivery *types.WebhookDelivery, disableAfter int) (bool, error) {
+	s.recorded = append(s.recorded, *delivery)
+	return false, nil
+}
+
+func newTestDelivery(url string) *types.WebhookDelivery {
+	return &types.WebhookDelivery{
+		ID:        7,
+		WebhookId: 3,
+		Event:     types.WebhookPostCreated,
+		Payload:   json.RawMessage(`{"id":1}`),
+		Status:    types.DeliveryPending,
+		Webhook:   &types.Webhook{ID: 3, URL: url, Secret: "whsec_test"},
+	}
+}
+
+func TestDeliverSignsTheRequest(t *testing.T) {
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		body, _ := io.ReadAll(req.Body)
+
+		timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("invalid timestamp header: %s", err)
+		}
+
+		if got, want := req.Header.Get(HeaderSignature), "sha256="+Sign("whsec_test", timestamp, body); got != want {
+			t.Errorf("expected signature %q, got %q", want, got)
+		}
+		if got := req.Header.Get(HeaderEvent); got != types.WebhookPostCreated {
+			t.Errorf("expected event %q, got %q", types.WebhookPostCreated, got)
+		}
+		if got := req.Header.Get(HeaderDelivery); got != "7" {
+			t.Errorf("expected delivery %q, got %q", "7", got)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	fake := &fakeWebhookStore{}
+	dispatcher := NewDispatcher(fake, 3, 10)
+	dispatcher.client = &http.Client{Timeout: time.Second} // the receiver listens on the loopback address
+
+	delivery := newTestDelivery(receiver.URL)
+	if err := dispatcher.Deliver(context.Background(), delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != types.DeliverySucceeded {
+		t.Errorf("expected status %q, got %q (%s)", types.DeliverySucceeded, delivery.Status, delivery.Error)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusNoContent {
+		t.Errorf("expected response status %d, got %v", http.StatusNoContent, delivery.ResponseStatus)
+	}
+	if len(fake.recorded) != 1 {
+		t.Errorf("expected 1 recorded attempt, got %d", len(fake.recorded))
+	}
+}
+
+func TestDeliverRetriesUntilAttemptsRunOut(t *testing.T) {
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("boom"))
+	}))
+	defer receiver.Close()
+
+	fake := &fakeWebhookStore{}
+	dispatcher := NewDispatcher(fake, 2, 10)
+	dispatcher.client = &http.Client{Timeout: time.Second} // the receiver listens on the loopback address
+
+	delivery := newTestDelivery(receiver.URL)
+
+	if err := dispatcher.Deliver(context.Background(), delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != types.DeliveryPending {
+		t.Fatalf("expected status %q after the first attempt, got %q", types.DeliveryPending, delivery.Status)
+	}
+	if delivery.NextAttemptAt == nil || time.Until(*delivery.NextAttemptAt) < baseBackoff-time.Second {
+		t.Errorf("expected a retry in about %s, got %v", baseBackoff, delivery.NextAttemptAt)
+	}
+
+	if err := dispatcher.Deliver(context.Background(), delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != types.DeliveryFailed {
+		t.Errorf("expected status %q after the last attempt, got %q", types.DeliveryFailed, delivery.Status)
+	}
+	if delivery.NextAttemptAt != nil {
+		t.Errorf("expected no further attempt, got %v", delivery.NextAttemptAt)
+	}
+	if delivery.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", delivery.Attempts)
+	}
+}
+
+func TestDeliverRefusesInternalAddresses(t *testing.T) {
+
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	fake := &fakeWebhookStore{}
+	dispatcher := NewDispatcher(fake, 3, 10)
+
+	// the receiver listens on the loopback address
+	delivery := newTestDelivery(receiver.URL)
+	if err := dispatcher.Deliver(context.Background(), delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if called {
+		t.Error("expected the request not to reach the receiver")
+	}
+	if !strings.Contains(delivery.Error, ErrBlockedAddress.Error()) {
+		t.Errorf("expected a blocked address error, got %q", delivery.Error)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("IsPublic(%s): expected %v, got %v", tt.addr, tt.public, got)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	tests := []struct {
+		attempts int
+		backoff  time.Duration
+	}{
+		{1, baseBackoff},
+		{2, baseBackoff * 2},
+		{4, baseBackoff * 8},
+		{30, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.backoff {
+			t.Errorf("Backoff(%d): expected %s, got %s", tt.attempts, tt.backoff, got)
+		}
+	}
+}